@@ -0,0 +1,68 @@
+package modbus
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrBCD signals a register value with a nibble over 9.
+var ErrBCD = errors.New("Modbus register value is not packed BCD")
+
+// RegBCD decodes a register with 4 digits of packed BCD, i.e., one decimal
+// digit per nibble, with the most significant digit first.
+func RegBCD(r uint16) (uint, error) {
+	var v uint
+	for shift := 12; shift >= 0; shift -= 4 {
+		digit := uint(r>>shift) & 0xf
+		if digit > 9 {
+			return 0, fmt.Errorf("%w: got %#04x", ErrBCD, r)
+		}
+		v = v*10 + digit
+	}
+	return v, nil
+}
+
+// PutRegBCD encodes a register with 4 digits of packed BCD. The return is
+// ErrValueRange when v exceeds 9999.
+func PutRegBCD(v uint) (uint16, error) {
+	if v > 9999 {
+		return 0, ErrValueRange
+	}
+	var r uint16
+	for shift := 0; shift < 16; shift += 4 {
+		r |= uint16(v%10) << shift
+		v /= 10
+	}
+	return r, nil
+}
+
+// RegsBCD decodes registers with packed BCD as a single number, with the most
+// significant register first. The return is ErrLimit for more than 4 registers,
+// i.e., 16 digits.
+func RegsBCD(regs []uint16) (uint64, error) {
+	if len(regs) > 4 {
+		return 0, ErrLimit
+	}
+	var v uint64
+	for _, r := range regs {
+		part, err := RegBCD(r)
+		if err != nil {
+			return 0, err
+		}
+		v = v*10000 + uint64(part)
+	}
+	return v, nil
+}
+
+// PutRegsBCD encodes v with packed BCD over registers, with the most
+// significant register first. The return is ErrValueRange when v does not fit.
+func PutRegsBCD(regs []uint16, v uint64) error {
+	for i := len(regs) - 1; i >= 0; i-- {
+		regs[i], _ = PutRegBCD(uint(v % 10000))
+		v /= 10000
+	}
+	if v != 0 {
+		return ErrValueRange
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package modbus_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pascaldekloe/modbus"
+)
+
+func TestRegBCD(t *testing.T) {
+	if got, err := modbus.RegBCD(0x1234); err != nil || got != 1234 {
+		t.Errorf("got (%d, %v), want (1234, nil)", got, err)
+	}
+	if _, err := modbus.RegBCD(0x12a4); !errors.Is(err, modbus.ErrBCD) {
+		t.Errorf("got error %v for nibble 0xa, want ErrBCD", err)
+	}
+
+	if got, err := modbus.PutRegBCD(907); err != nil || got != 0x0907 {
+		t.Errorf("got (%#04x, %v), want (0x0907, nil)", got, err)
+	}
+	if _, err := modbus.PutRegBCD(10000); err != modbus.ErrValueRange {
+		t.Errorf("got error %v for 10000, want ErrValueRange", err)
+	}
+}
+
+func TestRegsBCD(t *testing.T) {
+	regs := make([]uint16, 3)
+	if err := modbus.PutRegsBCD(regs, 12345678); err != nil {
+		t.Fatal(err)
+	}
+	if regs[0] != 0 || regs[1] != 0x1234 || regs[2] != 0x5678 {
+		t.Errorf("got registers %#04x, want [0 0x1234 0x5678]", regs)
+	}
+	if got, err := modbus.RegsBCD(regs); err != nil || got != 12345678 {
+		t.Errorf("got (%d, %v), want (12345678, nil)", got, err)
+	}
+
+	if err := modbus.PutRegsBCD(regs[:1], 12345); err != modbus.ErrValueRange {
+		t.Errorf("got error %v for 5 digits in 1 register, want ErrValueRange", err)
+	}
+}
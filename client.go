@@ -0,0 +1,194 @@
+package modbus
+
+import "encoding/binary"
+
+// Transport does transactions, independent of the wire protocol. Both the
+// request and the response are PDU data, i.e., the bytes which follow the
+// function code. Exception responses return as an Exception error, which may
+// be wrapped, e.g., in a RequestError. Broadcasts return a nil response.
+// Response bytes may stop being valid at the next invocation to the Transport.
+type Transport interface {
+	Exchange(funcCode byte, req []byte) (res []byte, err error)
+}
+
+// Exchange implements the Transport interface.
+func (f TxFunc) Exchange(funcCode byte, req []byte) (res []byte, err error) {
+	return f(funcCode, req)
+}
+
+// Client provides the function codes on any Transport. The TCPClient has its
+// own implementation with more features.
+type Client struct {
+	Transport
+}
+
+// ReadInputReg fetches an input register at the given address.
+func (c Client) ReadInputReg(addr uint16) (uint16, error) {
+	var buf [1]uint16
+	err := c.readRegs(buf[:], addr, readInputRegs)
+	return buf[0], err
+}
+
+// ReadHoldReg fetches a holding register at the given address.
+func (c Client) ReadHoldReg(addr uint16) (uint16, error) {
+	var buf [1]uint16
+	err := c.readRegs(buf[:], addr, readHoldRegs)
+	return buf[0], err
+}
+
+// ReadInputRegs fetches consecutive input-registers at a start address into a
+// read buffer. The return is ErrLimit when buf is larger than 125 entries.
+func (c Client) ReadInputRegs(buf []uint16, startAddr uint16) error {
+	return c.readRegs(buf, startAddr, readInputRegs)
+}
+
+// ReadHoldRegs fetches consecutive holding-registers at a start address into a
+// read buffer. The return is ErrLimit when buf is larger than 125 entries.
+func (c Client) ReadHoldRegs(buf []uint16, startAddr uint16) error {
+	return c.readRegs(buf, startAddr, readHoldRegs)
+}
+
+func (c Client) readRegs(buf []uint16, startAddr uint16, funcCode byte) error {
+	if len(buf) == 0 {
+		return nil // allowed
+	}
+	if len(buf) > 125 {
+		return ErrLimit
+	}
+	err := checkAddrRange(startAddr, len(buf))
+	if err != nil {
+		return err
+	}
+
+	var req [4]byte
+	binary.BigEndian.PutUint32(req[:], uint32(startAddr)<<16|uint32(len(buf)))
+	res, err := c.Exchange(funcCode, req[:])
+	if err != nil {
+		return err
+	}
+
+	if len(res) != 1+len(buf)*2 || int(res[0]) != len(buf)*2 {
+		return errFrameFit
+	}
+	for i := range buf {
+		buf[i] = binary.BigEndian.Uint16(res[1+i*2 : 3+i*2])
+	}
+	return nil
+}
+
+// ReadCoils fetches consecutive coils at a start address into a read buffer.
+// The return is ErrLimit when buf is larger than 2000 entries.
+func (c Client) ReadCoils(buf []bool, startAddr uint16) error {
+	return c.readBools(buf, startAddr, readCoils)
+}
+
+// ReadDiscreteInputs fetches consecutive discrete inputs at a start address
+// into a read buffer. The return is ErrLimit when buf is larger than 2000
+// entries.
+func (c Client) ReadDiscreteInputs(buf []bool, startAddr uint16) error {
+	return c.readBools(buf, startAddr, readDiscreteInputs)
+}
+
+func (c Client) readBools(buf []bool, startAddr uint16, funcCode byte) error {
+	if len(buf) == 0 {
+		return nil // allowed
+	}
+	if len(buf) > 2000 {
+		return ErrLimit
+	}
+	err := checkAddrRange(startAddr, len(buf))
+	if err != nil {
+		return err
+	}
+
+	var req [4]byte
+	binary.BigEndian.PutUint32(req[:], uint32(startAddr)<<16|uint32(len(buf)))
+	res, err := c.Exchange(funcCode, req[:])
+	if err != nil {
+		return err
+	}
+
+	byteN := (len(buf) + 7) / 8
+	if len(res) != 1+byteN || int(res[0]) != byteN {
+		return errFrameFit
+	}
+	for i := range buf {
+		buf[i] = res[1+i/8]&(1<<(i%8)) != 0
+	}
+	return nil
+}
+
+// WriteReg updates a single register.
+func (c Client) WriteReg(addr, value uint16) error {
+	return c.writeSingle(addr, value, writeReg)
+}
+
+// WriteCoil switches a single coil.
+func (c Client) WriteCoil(addr uint16, on bool) error {
+	var value uint16 // off
+	if on {
+		value = 0xff00
+	}
+	return c.writeSingle(addr, value, writeCoil)
+}
+
+func (c Client) writeSingle(addr, value uint16, funcCode byte) error {
+	order := uint32(addr)<<16 | uint32(value)
+	var req [4]byte
+	binary.BigEndian.PutUint32(req[:], order)
+	res, err := c.Exchange(funcCode, req[:])
+	if err != nil || res == nil {
+		return err
+	}
+
+	if len(res) != 4 {
+		return errFrameFit
+	}
+	did := binary.BigEndian.Uint32(res)
+	if did != order {
+		if did>>16 != order>>16 {
+			return errAddrMatch
+		}
+		return errValueMatch
+	}
+	return nil
+}
+
+// WriteRegs updates consecutive registers at a start address.
+// The return is ErrLimit when more than 123 values are given.
+func (c Client) WriteRegs(startAddr uint16, values ...uint16) error {
+	if len(values) == 0 {
+		return nil // allow
+	}
+	if len(values) > 123 {
+		return ErrLimit
+	}
+	err := checkAddrRange(startAddr, len(values))
+	if err != nil {
+		return err
+	}
+
+	order := uint32(startAddr)<<16 | uint32(len(values))
+	var req [5 + 2*123]byte
+	binary.BigEndian.PutUint32(req[:4], order)
+	req[4] = byte(len(values) * 2)
+	for i := range values {
+		binary.BigEndian.PutUint16(req[5+(2*i):7+(2*i)], values[i])
+	}
+	res, err := c.Exchange(writeRegs, req[:5+(2*len(values))])
+	if err != nil || res == nil {
+		return err
+	}
+
+	if len(res) != 4 {
+		return errFrameFit
+	}
+	did := binary.BigEndian.Uint32(res)
+	if did != order {
+		if did>>16 != order>>16 {
+			return errAddrMatch
+		}
+		return errWriteNMatch
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package modbus
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestClientTransport(t *testing.T) {
+	c := Client{TxFunc(func(funcCode byte, req []byte) ([]byte, error) {
+		switch funcCode {
+		case readInputRegs:
+			return []byte{2, 0xca, 0xfe}, nil
+		case writeReg:
+			return []byte{req[0], req[1] + 1, req[2], req[3]}, nil
+		}
+		return nil, ErrFunc
+	})}
+
+	got, err := c.ReadInputReg(7)
+	if err != nil {
+		t.Fatal("read input register error:", err)
+	}
+	if got != 0xcafe {
+		t.Errorf("got input register %#04x, want 0xcafe", got)
+	}
+
+	if err := c.WriteReg(7, 99); err != errAddrMatch {
+		t.Errorf("got write register error %v, want %v", err, errAddrMatch)
+	}
+	if err := c.WriteCoil(7, true); !errors.Is(err, ErrFunc) {
+		t.Errorf("got write coil error %v, want %v", err, ErrFunc)
+	}
+}
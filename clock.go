@@ -0,0 +1,50 @@
+package modbus
+
+import "time"
+
+// ClockLayout locates the date and time of a device clock in holding registers,
+// with one field per register. Each field has its offset from a start address.
+type ClockLayout struct {
+	Year, Month, Day, Hour, Minute, Second int
+
+	// YearBase is added to the year register, e.g., 2000 for two digits.
+	YearBase int
+
+	// Location of the clock, with nil for UTC.
+	Location *time.Location
+}
+
+// ReadClock fetches the device clock conform layout. Drift is the device time
+// minus the host time, as observed halfway the transaction.
+func (c *TCPClient) ReadClock(startAddr uint16, layout ClockLayout) (device time.Time, drift time.Duration, err error) {
+	offsets := [...]int{layout.Year, layout.Month, layout.Day,
+		layout.Hour, layout.Minute, layout.Second}
+	n := 1
+	for _, o := range offsets {
+		if o < 0 {
+			return time.Time{}, 0, ErrLimit
+		}
+		n = max(n, o+1)
+	}
+
+	var regs [125]uint16
+	if n > len(regs) {
+		return time.Time{}, 0, ErrLimit
+	}
+	start := time.Now()
+	err = c.ReadHoldRegs(regs[:n], startAddr)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	host := start.Add(time.Since(start) / 2)
+
+	loc := layout.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	device = time.Date(layout.YearBase+int(regs[layout.Year]),
+		time.Month(regs[layout.Month]), int(regs[layout.Day]),
+		int(regs[layout.Hour]), int(regs[layout.Minute]),
+		int(regs[layout.Second]), 0, loc)
+	return device, device.Sub(host), nil
+}
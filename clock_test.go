@@ -0,0 +1,72 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReadClock(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("time zone unavailable:", err)
+	}
+
+	tests := []struct {
+		name   string
+		layout ClockLayout
+		// register values from address 40
+		regs []uint16
+		want time.Time
+	}{
+		{"UTC", ClockLayout{Year: 0, Month: 1, Day: 2, Hour: 3, Minute: 4, Second: 5},
+			[]uint16{2024, 2, 29, 23, 59, 58},
+			time.Date(2024, 2, 29, 23, 59, 58, 0, time.UTC)},
+		{"reversed with gap", ClockLayout{Year: 6, Month: 5, Day: 4, Hour: 2, Minute: 1, Second: 0, YearBase: 2000, Location: berlin},
+			[]uint16{7, 30, 12, 0xffff, 15, 6, 25},
+			time.Date(2025, 6, 15, 12, 30, 7, 0, berlin)},
+	}
+	for _, test := range tests {
+		c := TCPClient{
+			Conn: &fakeDevice{respond: func(req []byte) []byte {
+				start := int(binary.BigEndian.Uint16(req[8:10]))
+				n := int(binary.BigEndian.Uint16(req[10:12]))
+				data := []byte{byte(2 * n)}
+				for addr := start; addr < start+n; addr++ {
+					var v uint16
+					if i := addr - 40; i >= 0 && i < len(test.regs) {
+						v = test.regs[i]
+					}
+					data = binary.BigEndian.AppendUint16(data, v)
+				}
+				return responseFrame(req, data...)
+			}},
+			UnitID: 1,
+		}
+
+		before := time.Now()
+		got, drift, err := c.ReadClock(40, test.layout)
+		after := time.Now()
+		if err != nil {
+			t.Errorf("%s: got error: %s", test.name, err)
+			continue
+		}
+		if !got.Equal(test.want) || got.Location() != test.want.Location() {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+		if drift > test.want.Sub(before) || drift < test.want.Sub(after) {
+			t.Errorf("%s: got drift %s, want between %s and %s", test.name, drift, test.want.Sub(after), test.want.Sub(before))
+		}
+	}
+
+	var c TCPClient
+	for _, layout := range []ClockLayout{
+		{Year: -1, Month: 1, Day: 2, Hour: 3, Minute: 4, Second: 5},
+		{Year: 125, Month: 1, Day: 2, Hour: 3, Minute: 4, Second: 5},
+	} {
+		if _, _, err := c.ReadClock(40, layout); !errors.Is(err, ErrLimit) {
+			t.Errorf("layout %+v got error %v, want ErrLimit", layout, err)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package modbus
+
+import "errors"
+
+// ConformanceClass returns the highest class fully supported by the device,
+// with -1 for none. Class 0 has function codes 0x03 and 0x10. Class 1 adds
+// function codes 0x01, 0x02, 0x04, 0x05, 0x06, 0x07 and 0x0F. Class 2 adds
+// function codes 0x14, 0x15, 0x16, 0x17 and 0x18.
+//
+// Probes are well-formed, and none of them writes. Reads are of a single value
+// at address 0. Updates are requested with a zero quantity or an illegal coil
+// value, which the specification rejects with ErrValue. Function codes 0x06
+// and 0x16 have no illegal value. Their probes go to holding register 0xFFFF,
+// only when a read of it gets ErrAddr, such that the updates get ErrAddr too.
+// The mask write is a no-op regardless, with AND 0xFFFF and OR 0x0000. Any
+// response counts as support, including exceptions, except for ErrFunc. Thus,
+// devices with a holding register 0xFFFF get class 0 at most.
+func (c *TCPClient) ConformanceClass() (int, error) {
+	classes := [...][]struct {
+		funcCode byte
+		data     []byte
+	}{
+		{
+			{readHoldRegs, []byte{0, 0, 0, 1}},
+			{writeRegs, []byte{0, 0, 0, 0, 0}},
+		}, {
+			{readCoils, []byte{0, 0, 0, 1}},
+			{readDiscreteInputs, []byte{0, 0, 0, 1}},
+			{readInputRegs, []byte{0, 0, 0, 1}},
+			{writeCoil, []byte{0, 0, 0x12, 0x34}},
+			{writeReg, []byte{0xff, 0xff, 0, 0}},
+			{readExceptStatus, nil},
+			{writeCoils, []byte{0, 0, 0, 0, 0}},
+		}, {
+			// file 1, record 0, length 1
+			{readFile, []byte{7, 6, 0, 1, 0, 0, 0, 1}},
+			{writeFile, []byte{0}},
+			{maskWriteReg, []byte{0xff, 0xff, 0xff, 0xff, 0, 0}},
+			{readWriteRegs, []byte{0, 0, 0, 0, 0, 0, 0, 0, 0}},
+			{readFIFO, []byte{0, 0}},
+		},
+	}
+
+	for class, probes := range classes {
+		for _, p := range probes {
+			if p.funcCode == writeReg || p.funcCode == maskWriteReg {
+				absent, err := c.lastHoldRegAbsent()
+				if err != nil {
+					return 0, err
+				}
+				if !absent {
+					return class - 1, nil
+				}
+			}
+
+			ok, err := c.supports(p.funcCode, p.data)
+			if err != nil {
+				return 0, err
+			}
+			if !ok {
+				return class - 1, nil
+			}
+		}
+	}
+	return len(classes) - 1, nil
+}
+
+// LastHoldRegAbsent returns whether a read of holding register 0xFFFF gets
+// ErrAddr in return.
+func (c *TCPClient) lastHoldRegAbsent() (bool, error) {
+	_, err := c.ReadHoldReg(0xffff)
+	switch {
+	case err == nil:
+		return false, nil
+	case errors.Is(err, ErrAddr):
+		return true, nil
+	case errors.As(err, new(Exception)):
+		return false, nil
+	}
+	return false, err
+}
+
+// Supports returns whether a request gets anything but ErrFunc in return.
+func (c *TCPClient) supports(funcCode byte, data []byte) (bool, error) {
+	n := copy(c.buf[8:], data)
+	_, err := c.sendAndReceive(c.buf[:8+n], funcCode)
+	var e Exception
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.As(err, &e):
+		return e != ErrFunc, nil
+	}
+	return false, err
+}
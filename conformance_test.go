@@ -0,0 +1,66 @@
+package modbus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestConformanceClass(t *testing.T) {
+	all := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x0f, 0x10, 0x14, 0x15, 0x16, 0x17, 0x18}
+	tests := []struct {
+		name      string
+		funcCodes []byte // supported
+		lastReg   bool   // holding register 0xFFFF present
+		want      int
+	}{
+		{"none", nil, false, -1},
+		{"class 0 partial", []byte{0x03}, false, -1},
+		{"class 0", []byte{0x03, 0x10}, false, 0},
+		{"class 1", all[:9], false, 1},
+		{"class 2", all, false, 2},
+		{"class 2 with holding register 0xFFFF", all, true, 0},
+	}
+	for _, test := range tests {
+		c := TCPClient{
+			Conn: &fakeDevice{respond: func(req []byte) []byte {
+				var addr uint16
+				if len(req) >= 10 {
+					addr = binary.BigEndian.Uint16(req[8:10])
+				}
+				exc := ErrFunc
+				switch {
+				case bytes.IndexByte(test.funcCodes, req[7]) < 0:
+					break
+				case req[7] == readHoldRegs && addr == 0xffff && test.lastReg:
+					return responseFrame(req, 2, 0, 42)
+				case req[7] == readHoldRegs && addr == 0xffff:
+					exc = ErrAddr
+				case req[7] == readHoldRegs:
+					return responseFrame(req, 2, 0, 42)
+				case req[7] == writeReg, req[7] == maskWriteReg:
+					if addr != 0xffff || test.lastReg {
+						t.Errorf("%s: got write %#x to a present register", test.name, req[7:])
+					}
+					exc = ErrAddr
+				default:
+					// zero quantity or illegal value
+					exc = ErrValue
+				}
+				frame := responseFrame(req, byte(exc))
+				frame[7] |= errorFlag
+				return frame
+			}},
+			UnitID: 1,
+		}
+
+		got, err := c.ConformanceClass()
+		if err != nil {
+			t.Errorf("%s: got error %v", test.name, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: got class %d, want %d", test.name, got, test.want)
+		}
+	}
+}
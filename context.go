@@ -0,0 +1,62 @@
+package modbus
+
+import (
+	"context"
+	"time"
+)
+
+// WithContext runs f with any transactions bound to ctx. Cancellation of ctx
+// aborts the transaction in progress, if any, and it fails the transactions
+// which follow. The deadline of ctx, if any, applies conform SetTxDeadline.
+//
+//	err := client.WithContext(ctx, func() error {
+//		v, err = client.ReadHoldReg(1001)
+//		return err
+//	})
+func (c *TCPClient) WithContext(ctx context.Context, f func() error) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		if c.txDeadline.IsZero() || deadline.Before(c.txDeadline) {
+			defer func(restore time.Time) {
+				c.txDeadline = restore
+			}(c.txDeadline)
+			c.txDeadline = deadline
+		}
+	}
+
+	defer func(restore context.Context) {
+		c.ctx = restore
+	}(c.ctx)
+	c.ctx = ctx
+
+	return f()
+}
+
+// ReadInputRegsContext is like ReadInputRegs, yet bound to ctx conform
+// WithContext.
+func (c *TCPClient) ReadInputRegsContext(ctx context.Context, buf []uint16, startAddr uint16) error {
+	return c.WithContext(ctx, func() error {
+		return c.ReadInputRegs(buf, startAddr)
+	})
+}
+
+// ReadHoldRegsContext is like ReadHoldRegs, yet bound to ctx conform
+// WithContext.
+func (c *TCPClient) ReadHoldRegsContext(ctx context.Context, buf []uint16, startAddr uint16) error {
+	return c.WithContext(ctx, func() error {
+		return c.ReadHoldRegs(buf, startAddr)
+	})
+}
+
+// WriteRegContext is like WriteReg, yet bound to ctx conform WithContext.
+func (c *TCPClient) WriteRegContext(ctx context.Context, addr, value uint16) error {
+	return c.WithContext(ctx, func() error {
+		return c.WriteReg(addr, value)
+	})
+}
+
+// WriteRegsContext is like WriteRegs, yet bound to ctx conform WithContext.
+func (c *TCPClient) WriteRegsContext(ctx context.Context, startAddr uint16, values ...uint16) error {
+	return c.WithContext(ctx, func() error {
+		return c.WriteRegs(startAddr, values...)
+	})
+}
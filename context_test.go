@@ -0,0 +1,29 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestContextCancel(t *testing.T) {
+	clientEnd, deviceEnd := net.Pipe()
+	defer deviceEnd.Close()
+	go io.Copy(io.Discard, deviceEnd) // no response
+
+	c := TCPClient{Conn: clientEnd, UnitID: 1}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	var buf [2]uint16
+	err := c.ReadHoldRegsContext(ctx, buf[:], 1001)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want context.Canceled", err)
+	}
+	if c.ctx != nil {
+		t.Error("context binding remains")
+	}
+}
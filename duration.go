@@ -0,0 +1,36 @@
+package modbus
+
+import "time"
+
+// RegDurationSeconds returns an unsigned integer of seconds, with a byte count
+// of 2, 4 or 8 in p.
+func RegDurationSeconds(p []byte, order RegOrder) time.Duration {
+	return time.Duration(order.uint(p)) * time.Second
+}
+
+// RegDurationMillis returns an unsigned integer of milliseconds, with a byte
+// count of 2, 4 or 8 in p.
+func RegDurationMillis(p []byte, order RegOrder) time.Duration {
+	return time.Duration(order.uint(p)) * time.Millisecond
+}
+
+// PutRegDurationSeconds places d as an unsigned integer of seconds, with a
+// byte count of 2, 4 or 8 in p. Any fraction is truncated. The return is
+// ErrValueRange when d is negative or when d does not fit in p.
+func PutRegDurationSeconds(p []byte, order RegOrder, d time.Duration) error {
+	return putRegDuration(p, order, d, time.Second)
+}
+
+// PutRegDurationMillis places d as an unsigned integer of milliseconds, with a
+// byte count of 2, 4 or 8 in p. Any fraction is truncated. The return is
+// ErrValueRange when d is negative or when d does not fit in p.
+func PutRegDurationMillis(p []byte, order RegOrder, d time.Duration) error {
+	return putRegDuration(p, order, d, time.Millisecond)
+}
+
+func putRegDuration(p []byte, order RegOrder, d, unit time.Duration) error {
+	if d < 0 || !order.putUint(p, uint64(d/unit)) {
+		return ErrValueRange
+	}
+	return nil
+}
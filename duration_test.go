@@ -0,0 +1,28 @@
+package modbus_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pascaldekloe/modbus"
+)
+
+func TestRegDuration(t *testing.T) {
+	var p [4]byte
+	err := modbus.PutRegDurationSeconds(p[:], modbus.CDAB, 100_000*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [4]byte{0x86, 0xa0, 0x00, 0x01}; p != want {
+		t.Errorf("got %#x, want %#x", p, want)
+	}
+	if got := modbus.RegDurationSeconds(p[:], modbus.CDAB); got != 100_000*time.Second {
+		t.Errorf("got %s, want 100000s", got)
+	}
+
+	err = modbus.PutRegDurationMillis(p[:2], modbus.ABCD, 2*time.Minute)
+	if !errors.Is(err, modbus.ErrValueRange) {
+		t.Errorf("got error %v for 120000 ms, want ErrValueRange", err)
+	}
+}
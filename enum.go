@@ -0,0 +1,41 @@
+package modbus
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrEnumRange signals a register value outside of an Enum.
+var ErrEnumRange = errors.New("Modbus register value outside enumeration")
+
+// Enum labels the contiguous register values Min up until Min + len(Labels).
+type Enum struct {
+	Min    uint16
+	Labels []string
+}
+
+// Label returns the name of r, with ok false when r is out of range.
+func (e Enum) Label(r uint16) (label string, ok bool) {
+	if r < e.Min || int(r-e.Min) >= len(e.Labels) {
+		return "", false
+	}
+	return e.Labels[r-e.Min], true
+}
+
+// Decode returns the name of r, with ErrEnumRange when r is out of range.
+func (e Enum) Decode(r uint16) (string, error) {
+	label, ok := e.Label(r)
+	if !ok {
+		return "", fmt.Errorf("%w: got %#04x", ErrEnumRange, r)
+	}
+	return label, nil
+}
+
+// Name returns the label of r, with an "unknown" marker when r is out of range.
+func (e Enum) Name(r uint16) string {
+	label, ok := e.Label(r)
+	if !ok {
+		return fmt.Sprintf("unknown (%#04x)", r)
+	}
+	return label
+}
@@ -0,0 +1,25 @@
+package modbus_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pascaldekloe/modbus"
+)
+
+func TestEnum(t *testing.T) {
+	e := modbus.Enum{Min: 1, Labels: []string{"idle", "run", "fault"}}
+
+	if got, err := e.Decode(3); err != nil || got != "fault" {
+		t.Errorf("got %q, %v for 3, want fault", got, err)
+	}
+	for _, r := range []uint16{0, 4, 0xffff} {
+		_, err := e.Decode(r)
+		if !errors.Is(err, modbus.ErrEnumRange) {
+			t.Errorf("got error %v for %#04x, want ErrEnumRange", err, r)
+		}
+	}
+	if got, want := e.Name(0xffff), "unknown (0xffff)"; got != want {
+		t.Errorf("got name %q, want %q", got, want)
+	}
+}
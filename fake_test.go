@@ -0,0 +1,49 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"time"
+)
+
+// FakeDevice is a net.Conn which answers each request with a frame from
+// respond. Responses stay pending until read.
+type fakeDevice struct {
+	respond func(req []byte) []byte
+	// optional limit on the number of bytes per read for fragmentation
+	chunk   int
+	pending []byte
+}
+
+func (d *fakeDevice) Write(p []byte) (int, error) {
+	d.pending = d.respond(append([]byte(nil), p...))
+	return len(p), nil
+}
+
+func (d *fakeDevice) Read(p []byte) (int, error) {
+	if len(d.pending) == 0 {
+		return 0, os.ErrDeadlineExceeded
+	}
+	if d.chunk > 0 && len(p) > d.chunk {
+		p = p[:d.chunk]
+	}
+	n := copy(p, d.pending)
+	d.pending = d.pending[n:]
+	return n, nil
+}
+
+func (d *fakeDevice) Close() error                       { return nil }
+func (d *fakeDevice) LocalAddr() net.Addr                { return nil }
+func (d *fakeDevice) RemoteAddr() net.Addr               { return nil }
+func (d *fakeDevice) SetDeadline(t time.Time) error      { return nil }
+func (d *fakeDevice) SetReadDeadline(t time.Time) error  { return nil }
+func (d *fakeDevice) SetWriteDeadline(t time.Time) error { return nil }
+
+// ResponseFrame returns an MBAP frame for req with the PDU data given. The
+// function code is copied from the request.
+func responseFrame(req []byte, data ...byte) []byte {
+	frame := append(append([]byte(nil), req[:8]...), data...)
+	binary.BigEndian.PutUint16(frame[4:6], uint16(len(frame)-6))
+	return frame
+}
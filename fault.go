@@ -0,0 +1,55 @@
+package modbus
+
+import "slices"
+
+// RegsFlags returns the indices of each bit set in regs, in ascending order.
+// Bit 0 is the least significant bit of the first register, bit 16 is the
+// least significant bit of the second register, and so on.
+func RegsFlags(regs []uint16) []int {
+	var flags []int
+	for i, r := range regs {
+		for bit := 0; r != 0; bit++ {
+			if r&1 != 0 {
+				flags = append(flags, i*16+bit)
+			}
+			r >>= 1
+		}
+	}
+	return flags
+}
+
+// Fault is an active bit from a fault-register block.
+type Fault struct {
+	Bit  int    // index conform RegsFlags
+	Name string // label from FaultMap, if any
+}
+
+// FaultMap ranks the bits from a fault-register block.
+type FaultMap struct {
+	// Priority lists bit indices in order of precedence. Bits absent from
+	// the list follow in ascending order.
+	Priority []int
+
+	// Optional labels per bit index.
+	Names map[int]string
+}
+
+// Active returns the faults set in regs, with the highest priority first.
+func (m *FaultMap) Active(regs []uint16) []Fault {
+	flags := RegsFlags(regs)
+	faults := make([]Fault, 0, len(flags))
+	listed := make(map[int]bool, len(m.Priority))
+	for _, bit := range m.Priority {
+		_, ok := slices.BinarySearch(flags, bit)
+		if ok && !listed[bit] {
+			faults = append(faults, Fault{Bit: bit, Name: m.Names[bit]})
+		}
+		listed[bit] = true
+	}
+	for _, bit := range flags {
+		if !listed[bit] {
+			faults = append(faults, Fault{Bit: bit, Name: m.Names[bit]})
+		}
+	}
+	return faults
+}
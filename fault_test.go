@@ -0,0 +1,24 @@
+package modbus_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pascaldekloe/modbus"
+)
+
+func TestFaultMapActive(t *testing.T) {
+	m := modbus.FaultMap{
+		Priority: []int{17, 3, 20},
+		Names:    map[int]string{3: "overheat", 17: "phase loss"},
+	}
+	got := m.Active([]uint16{0x0009, 0x0002})
+	want := []modbus.Fault{
+		{Bit: 17, Name: "phase loss"},
+		{Bit: 3, Name: "overheat"},
+		{Bit: 0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
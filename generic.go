@@ -0,0 +1,29 @@
+package modbus
+
+// ReadHoldRegsAs fetches n consecutive holding-registers at a start address,
+// and it decodes them with dec. The slice passed to dec has 2 bytes in big-
+// endian order per register. Bytes stop being valid when dec returns. The
+// return is ErrLimit when n is not in range [1, 125].
+func ReadHoldRegsAs[T any](c *TCPClient, n int, startAddr uint16, dec func([]byte) T) (T, error) {
+	return readRegsAs(c, n, startAddr, readHoldRegs, dec)
+}
+
+// ReadInputRegsAs fetches n consecutive input-registers at a start address,
+// and it decodes them with dec. The slice passed to dec has 2 bytes in big-
+// endian order per register. Bytes stop being valid when dec returns. The
+// return is ErrLimit when n is not in range [1, 125].
+func ReadInputRegsAs[T any](c *TCPClient, n int, startAddr uint16, dec func([]byte) T) (T, error) {
+	return readRegsAs(c, n, startAddr, readInputRegs, dec)
+}
+
+func readRegsAs[T any](c *TCPClient, n int, startAddr uint16, funcCode byte, dec func([]byte) T) (T, error) {
+	var zero T
+	if n < 1 || n > 125 {
+		return zero, ErrLimit
+	}
+	p, err := c.readNRegSlice(n, startAddr, funcCode)
+	if err != nil {
+		return zero, err
+	}
+	return dec(p), nil
+}
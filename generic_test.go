@@ -0,0 +1,48 @@
+package modbus
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReadRegsAs(t *testing.T) {
+	c := TCPClient{
+		Conn: &fakeDevice{respond: func(req []byte) []byte {
+			switch req[7] {
+			case readHoldRegs: // "Hi!"
+				return responseFrame(req, 4, 'H', 'i', '!', 0)
+			case readInputRegs: // 1.5
+				return responseFrame(req, 4, 0x3f, 0xc0, 0, 0)
+			}
+			return nil
+		}},
+		UnitID: 1,
+	}
+
+	s, err := ReadHoldRegsAs(&c, 2, 10, func(p []byte) string {
+		return string(p[:3])
+	})
+	if err != nil || s != "Hi!" {
+		t.Errorf("got (%q, %v), want (\"Hi!\", nil)", s, err)
+	}
+
+	f, err := ReadInputRegsAs(&c, 2, 20, func(p []byte) float32 {
+		return RegPairFloat((*[4]byte)(p))
+	})
+	if err != nil || f != 1.5 {
+		t.Errorf("got (%f, %v), want (1.5, nil)", f, err)
+	}
+
+	for _, n := range []int{-1, 0, 126} {
+		_, err := ReadHoldRegsAs(&c, n, 10, func(p []byte) int {
+			t.Errorf("%d registers: decoder invoked", n)
+			return 0
+		})
+		if !errors.Is(err, ErrLimit) {
+			t.Errorf("%d registers: got error %v, want ErrLimit", n, err)
+		}
+	}
+	if c.TxN != 2 {
+		t.Errorf("got %d transactions, want 2", c.TxN)
+	}
+}
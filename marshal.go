@@ -0,0 +1,364 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// StringType is the PointType for strings in struct tags, with two characters
+// per register, high byte first. The register count is mandatory with "len".
+const stringType PointType = "string"
+
+// FieldSpec is a parsed struct tag.
+type fieldSpec struct {
+	offset int       // register index
+	typ    PointType // encoding
+	order  RegOrder
+	n      int // register count
+}
+
+// ParseFieldTag reads a struct tag like "offset=4,type=float32,order=CDAB".
+// The type defaults to the kind of the field.
+func parseFieldTag(tag string, kind reflect.Kind) (fieldSpec, error) {
+	var spec fieldSpec
+	var hasOffset bool
+	for _, option := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(option, "=")
+		switch key {
+		case "offset":
+			n, err := strconv.ParseUint(value, 10, 16)
+			if err != nil {
+				return spec, fmt.Errorf("offset %q: %w", value, err)
+			}
+			spec.offset, hasOffset = int(n), true
+		case "type":
+			spec.typ = PointType(value)
+		case "order":
+			err := spec.order.UnmarshalText([]byte(value))
+			if err != nil {
+				return spec, err
+			}
+		case "len":
+			n, err := strconv.ParseUint(value, 10, 8)
+			if err != nil || n == 0 {
+				return spec, fmt.Errorf("len %q invalid", value)
+			}
+			spec.n = int(n)
+		default:
+			return spec, fmt.Errorf("option %q unknown", option)
+		}
+	}
+	if !hasOffset {
+		return spec, errors.New("offset missing")
+	}
+
+	if spec.typ == "" {
+		switch kind {
+		case reflect.Uint16:
+			spec.typ = Uint16
+		case reflect.Int16:
+			spec.typ = Int16
+		case reflect.Uint32:
+			spec.typ = Uint32
+		case reflect.Int32:
+			spec.typ = Int32
+		case reflect.Float32:
+			spec.typ = Float32
+		case reflect.Uint64:
+			spec.typ = Uint64
+		case reflect.Int64:
+			spec.typ = Int64
+		case reflect.Float64:
+			spec.typ = Float64
+		case reflect.String:
+			spec.typ = stringType
+		default:
+			return spec, fmt.Errorf("type needed for %s", kind)
+		}
+	}
+
+	if spec.typ == stringType {
+		if kind != reflect.String {
+			return spec, fmt.Errorf("type %s for %s", spec.typ, kind)
+		}
+		if spec.n == 0 {
+			return spec, errors.New("len missing for string")
+		}
+		return spec, nil
+	}
+	if spec.n != 0 {
+		return spec, fmt.Errorf("len with type %s", spec.typ)
+	}
+	spec.n = spec.typ.regN()
+	if spec.n == 0 {
+		return spec, fmt.Errorf("type %q unknown", spec.typ)
+	}
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return spec, nil
+	}
+	return spec, fmt.Errorf("type %s for %s", spec.typ, kind)
+}
+
+// StructFields returns the tagged fields of struct value v.
+func structFields(v reflect.Value) ([]fieldSpec, []reflect.Value, error) {
+	var specs []fieldSpec
+	var fields []reflect.Value
+	for i := range v.NumField() {
+		f := v.Type().Field(i)
+		tag, ok := f.Tag.Lookup("modbus")
+		if !ok || tag == "-" {
+			continue
+		}
+		if !f.IsExported() {
+			return nil, nil, fmt.Errorf("Modbus struct field %s not exported", f.Name)
+		}
+		spec, err := parseFieldTag(tag, f.Type.Kind())
+		if err != nil {
+			return nil, nil, fmt.Errorf("Modbus struct field %s: %w", f.Name, err)
+		}
+		specs = append(specs, spec)
+		fields = append(fields, v.Field(i))
+	}
+	return specs, fields, nil
+}
+
+// Unmarshal decodes registers into the fields of a struct with "modbus" tags.
+// The "offset" option sets the register index, relative to the start of regs.
+// The "type" option takes a PointType, or "string" with two characters per
+// register. Type defaults to the kind of the field. The "order" option takes a
+// RegOrder for types of multiple registers. The "len" option sets the register
+// count for strings. An example tag is `modbus:"offset=4,type=float32,order=CDAB"`.
+func Unmarshal(regs []uint16, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Modbus unmarshal needs a struct pointer; got %T", v)
+	}
+	specs, fields, err := structFields(rv.Elem())
+	if err != nil {
+		return err
+	}
+
+	for i, spec := range specs {
+		if spec.offset+spec.n > len(regs) {
+			return fmt.Errorf("Modbus struct field at offset %d exceeds %d registers", spec.offset, len(regs))
+		}
+		span := regs[spec.offset : spec.offset+spec.n]
+		f := fields[i]
+
+		if spec.typ == stringType {
+			f.SetString(RegsToString(span))
+			continue
+		}
+
+		var buf [8]byte
+		p := buf[:2*spec.n]
+		for j, r := range span {
+			binary.BigEndian.PutUint16(p[2*j:], r)
+		}
+		spec.order.swap(p)
+
+		switch spec.typ {
+		case Uint16:
+			err = setUint(f, uint64(binary.BigEndian.Uint16(p)))
+		case Int16:
+			err = setInt(f, int64(int16(binary.BigEndian.Uint16(p))))
+		case Uint32:
+			err = setUint(f, uint64(binary.BigEndian.Uint32(p)))
+		case Int32:
+			err = setInt(f, int64(int32(binary.BigEndian.Uint32(p))))
+		case Uint64:
+			err = setUint(f, binary.BigEndian.Uint64(p))
+		case Int64:
+			err = setInt(f, int64(binary.BigEndian.Uint64(p)))
+		case Float32:
+			err = setFloat(f, float64(math.Float32frombits(binary.BigEndian.Uint32(p))))
+		case Float64:
+			err = setFloat(f, math.Float64frombits(binary.BigEndian.Uint64(p)))
+		}
+		if err != nil {
+			return fmt.Errorf("Modbus struct field at offset %d: %w", spec.offset, err)
+		}
+	}
+	return nil
+}
+
+func setUint(f reflect.Value, u uint64) error {
+	switch {
+	case f.CanUint():
+		if f.OverflowUint(u) {
+			return fmt.Errorf("value %d overflows %s", u, f.Type())
+		}
+		f.SetUint(u)
+	case f.CanInt():
+		if u > math.MaxInt64 || f.OverflowInt(int64(u)) {
+			return fmt.Errorf("value %d overflows %s", u, f.Type())
+		}
+		f.SetInt(int64(u))
+	default:
+		f.SetFloat(float64(u))
+	}
+	return nil
+}
+
+func setInt(f reflect.Value, i int64) error {
+	switch {
+	case f.CanInt():
+		if f.OverflowInt(i) {
+			return fmt.Errorf("value %d overflows %s", i, f.Type())
+		}
+		f.SetInt(i)
+	case f.CanUint():
+		if i < 0 || f.OverflowUint(uint64(i)) {
+			return fmt.Errorf("value %d overflows %s", i, f.Type())
+		}
+		f.SetUint(uint64(i))
+	default:
+		f.SetFloat(float64(i))
+	}
+	return nil
+}
+
+func setFloat(f reflect.Value, x float64) error {
+	if !f.CanFloat() {
+		return fmt.Errorf("floating-point into %s", f.Type())
+	}
+	f.SetFloat(x)
+	return nil
+}
+
+// Marshal encodes the fields of a struct with "modbus" tags into registers,
+// conform Unmarshal. The register count is the highest offset plus its span.
+// Registers not covered by any field remain zero. The return is ErrValueRange
+// when a value does not fit its type.
+func Marshal(v any) ([]uint16, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("Modbus marshal needs a struct; got %T", v)
+	}
+	specs, fields, err := structFields(rv)
+	if err != nil {
+		return nil, err
+	}
+
+	var regN int
+	for _, spec := range specs {
+		regN = max(regN, spec.offset+spec.n)
+	}
+	regs := make([]uint16, regN)
+
+	for i, spec := range specs {
+		f := fields[i]
+		span := regs[spec.offset : spec.offset+spec.n]
+
+		if spec.typ == stringType {
+			s := f.String()
+			if len(s) > 2*spec.n {
+				return nil, fmt.Errorf("Modbus struct field at offset %d: string exceeds %d registers: %w",
+					spec.offset, spec.n, ErrValueRange)
+			}
+			copy(span, StringToRegs(s))
+			continue
+		}
+
+		var bits uint64
+		switch spec.typ {
+		case Float32:
+			bits = uint64(math.Float32bits(float32(floatOf(f))))
+		case Float64:
+			bits = math.Float64bits(floatOf(f))
+		default:
+			var ok bool
+			bits, ok = intBits(f, spec.typ)
+			if !ok {
+				return nil, fmt.Errorf("Modbus struct field at offset %d: %w", spec.offset, ErrValueRange)
+			}
+		}
+
+		var buf [8]byte
+		p := buf[:2*spec.n]
+		spec.order.putUint(p, bits)
+		for j := range span {
+			span[j] = binary.BigEndian.Uint16(p[2*j:])
+		}
+	}
+	return regs, nil
+}
+
+func floatOf(f reflect.Value) float64 {
+	switch {
+	case f.CanInt():
+		return float64(f.Int())
+	case f.CanUint():
+		return float64(f.Uint())
+	}
+	return f.Float()
+}
+
+// IntBits returns the two's complement of f in the width of typ, with ok false
+// when the value does not fit.
+func intBits(f reflect.Value, typ PointType) (bits uint64, ok bool) {
+	var i int64
+	var u uint64
+	signed := true
+	switch {
+	case f.CanInt():
+		i = f.Int()
+	case f.CanUint():
+		u, signed = f.Uint(), false
+	default:
+		x := f.Float()
+		if x != math.Trunc(x) {
+			return 0, false
+		}
+		if x < 0 {
+			if x < math.MinInt64 {
+				return 0, false
+			}
+			i = int64(x)
+		} else {
+			if x >= math.MaxUint64 {
+				return 0, false
+			}
+			u, signed = uint64(x), false
+		}
+	}
+	if signed && i >= 0 {
+		u, signed = uint64(i), false
+	}
+
+	switch typ {
+	case Uint16:
+		return u, !signed && u <= math.MaxUint16
+	case Int16:
+		if signed {
+			return uint64(uint16(i)), i >= math.MinInt16
+		}
+		return u, u <= math.MaxInt16
+	case Uint32:
+		return u, !signed && u <= math.MaxUint32
+	case Int32:
+		if signed {
+			return uint64(uint32(i)), i >= math.MinInt32
+		}
+		return u, u <= math.MaxInt32
+	case Uint64:
+		return u, !signed
+	case Int64:
+		if signed {
+			return uint64(i), true
+		}
+		return u, u <= math.MaxInt64
+	}
+	return 0, false
+}
@@ -0,0 +1,47 @@
+package modbus_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/pascaldekloe/modbus"
+)
+
+type meter struct {
+	Serial  string  `modbus:"offset=0,len=2"`
+	Status  uint16  `modbus:"offset=2"`
+	Temp    int     `modbus:"offset=3,type=int16"`
+	Power   float32 `modbus:"offset=4,order=CDAB"`
+	Energy  uint64  `modbus:"offset=6,type=uint32"`
+	Comment string
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	regs := []uint16{0x4142, 0x4300, 0x0011, 0xfff6, 0x0000, 0x3fc0, 0x0001, 0x0002}
+
+	var got meter
+	if err := modbus.Unmarshal(regs, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := meter{Serial: "ABC", Status: 0x11, Temp: -10, Power: 1.5, Energy: 0x10002}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	back, err := modbus.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, regs) {
+		t.Errorf("marshal got %#04x, want %#04x", back, regs)
+	}
+
+	if err := modbus.Unmarshal(regs[:7], &got); err == nil {
+		t.Error("no error for short register block")
+	}
+	got.Temp = 40000
+	if _, err := modbus.Marshal(got); !errors.Is(err, modbus.ErrValueRange) {
+		t.Errorf("got error %v for int16 overflow, want ErrValueRange", err)
+	}
+}
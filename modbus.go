@@ -12,6 +12,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"math/bits"
 )
 
 // Standardised Error Codes
@@ -53,12 +54,81 @@ func (e Exception) Error() string {
 	case ErrGateTarget:
 		return "Modbus exception 0x0B: gateway target device failed to respond"
 	}
-	return fmt.Sprintf("Modbus exception 0x%#02X", e)
+	return fmt.Sprintf("Modbus exception 0x%02X", byte(e))
 }
 
+// Temporary returns whether a retry may succeed. Devices respond with ErrBusy
+// and ErrAck while processing, and gateways with ErrGateTarget on a missing
+// response. Other exceptions, such as ErrFunc, ErrAddr and ErrValue, are
+// permanent for the request.
+func (e Exception) Temporary() bool {
+	switch e {
+	case ErrBusy, ErrAck, ErrGateTarget:
+		return true
+	}
+	return false
+}
+
+// RequestError is a transaction failure with details of the request. Use
+// errors.As to recover any Exception.
+type RequestError struct {
+	FuncCode byte
+	Addr     uint16 // start address, if any
+	UnitID   byte
+	Err      error // cause
+}
+
+// Error implements the builtin.error interface.
+func (e *RequestError) Error() string {
+	if hasAddr(e.FuncCode) {
+		return fmt.Sprintf("%s; function code %#02x to unit %#02x at address %d",
+			e.Err, e.FuncCode, e.UnitID, e.Addr)
+	}
+	return fmt.Sprintf("%s; function code %#02x to unit %#02x",
+		e.Err, e.FuncCode, e.UnitID)
+}
+
+// Unwrap returns the cause.
+func (e *RequestError) Unwrap() error { return e.Err }
+
+// Frame Limits
+const (
+	// MaxPDUSize is the protocol data unit limit, in bytes, which includes
+	// the function code. It applies to both requests and responses.
+	MaxPDUSize = 253
+
+	// MaxADUSize is the application data unit limit of Modbus TCP, in
+	// bytes, which includes the 7-byte MBAP header. Serial line frames
+	// have a 1-byte address and a 2-byte checksum instead.
+	MaxADUSize = 7 + MaxPDUSize
+)
+
 // ErrLimit denies a request based on the amount of values requested.
 var ErrLimit = errors.New("Modbus value count exceeds protocol limit")
 
+// ErrAddrRange denies a request with addresses beyond 0xFFFF, i.e., the start
+// address plus the number of values wraps around. Requests are not submitted.
+var ErrAddrRange = errors.New("Modbus address range exceeds 0xFFFF")
+
+// CheckAddrRange returns ErrAddrRange when n addresses at startAddr exceed
+// the address space.
+func checkAddrRange(startAddr uint16, n int) error {
+	if int(startAddr)+n > 0x10000 {
+		return ErrAddrRange
+	}
+	return nil
+}
+
+// ErrBroadcastRead denies a read request to unit identifier 0. Broadcasts
+// get no response.
+var ErrBroadcastRead = errors.New("Modbus read request to broadcast unit identifier 0")
+
+// ErrBitIndex denies a bit position beyond the 16 bits of a register.
+var ErrBitIndex = errors.New("Modbus register bit index exceeds 15")
+
+// ErrVerify signals a read-back which does not match the value written.
+var ErrVerify = errors.New("Modbus read-back does not match the value written")
+
 // Response Errors
 var (
 	errFrameFit    = errors.New("Modbus payload does not match frame size")
@@ -73,6 +143,14 @@ const (
 	writeCoil  = 0x05
 	writeCoils = 0x0f
 
+	readDiscreteInputs = 0x02
+	readExceptStatus   = 0x07
+	diagnostics        = 0x08
+	commEventCounter   = 0x0b
+	commEventLog       = 0x0c
+	reportServerID     = 0x11
+	encapsulated       = 0x2b // MEI transport
+
 	readInputRegs = 0x04
 	readHoldRegs  = 0x03
 	writeReg      = 0x06
@@ -87,6 +165,100 @@ const (
 	errorFlag = 0x80
 )
 
+// IsIdempotent returns whether the function code can be repeated without side
+// effects.
+func isIdempotent(funcCode byte) bool {
+	switch funcCode {
+	case readCoils, readDiscreteInputs, readHoldRegs, readInputRegs,
+		readExceptStatus, commEventCounter, commEventLog, readFile, readFIFO,
+		reportServerID, encapsulated:
+		return true
+	}
+	return false
+}
+
+// HasAddr returns whether the function code has a start address in the first
+// two bytes of the request data.
+func hasAddr(funcCode byte) bool {
+	switch funcCode {
+	case readCoils, readDiscreteInputs, readHoldRegs, readInputRegs,
+		writeCoil, writeCoils, writeReg, writeRegs, readWriteRegs,
+		maskWriteReg, readFIFO:
+		return true
+	}
+	return false
+}
+
+// IsWrite returns whether the function code is for updates only.
+func isWrite(funcCode byte) bool {
+	switch funcCode {
+	case writeCoil, writeCoils, writeReg, writeRegs, maskWriteReg, writeFile:
+		return true
+	}
+	return false
+}
+
+// Mutates returns whether the function code changes the state of a device,
+// which includes the combined read and write of function code 0x17.
+func mutates(funcCode byte) bool {
+	return isWrite(funcCode) || funcCode == readWriteRegs
+}
+
+// RegValid returns r, with ok false for the all-ones value 0xFFFF, which many
+// devices use to denote that no valid reading is available.
+func RegValid(r uint16) (v uint16, ok bool) {
+	return RegSentinel(r, 0xffff)
+}
+
+// RegSentinel returns r, with ok false when r equals the sentinel value.
+func RegSentinel(r, sentinel uint16) (v uint16, ok bool) {
+	return r, r != sentinel
+}
+
+// RegBit returns whether bit n of r is set, with bit 0 as the least
+// significant. The function panics on bit indices over 15.
+func RegBit(r uint16, n uint) bool {
+	if n > 15 {
+		panic(ErrBitIndex)
+	}
+	return r&(1<<n) != 0
+}
+
+// PutRegBit returns r with bit n set or cleared, with bit 0 as the least
+// significant. The function panics on bit indices over 15.
+func PutRegBit(r uint16, n uint, set bool) uint16 {
+	if n > 15 {
+		panic(ErrBitIndex)
+	}
+	if set {
+		return r | 1<<n
+	}
+	return r &^ (1 << n)
+}
+
+// RegBitRange returns bits lo through hi (inclusive) of r, shifted to bit 0.
+// The function panics on bit indices over 15, and on lo over hi.
+func RegBitRange(r uint16, lo, hi uint) uint16 {
+	if hi > 15 || lo > hi {
+		panic(ErrBitIndex)
+	}
+	return r >> lo & (1<<(hi-lo+1) - 1)
+}
+
+// RegPairValid extracts an unsigned integer from two registers, with ok false
+// for the all-ones value 0xFFFFFFFF, which many devices use to denote that no
+// valid reading is available.
+func RegPairValid(p *[4]byte) (v uint32, ok bool) {
+	return RegPairSentinel(p, 0xffffffff)
+}
+
+// RegPairSentinel extracts an unsigned integer from two registers, with ok
+// false when the value equals the sentinel.
+func RegPairSentinel(p *[4]byte, sentinel uint32) (v uint32, ok bool) {
+	v = binary.BigEndian.Uint32(p[:4])
+	return v, v != sentinel
+}
+
 // RegPairFloat extracts a single-precission floating-point from two registers.
 func RegPairFloat(p *[4]byte) float32 {
 	bits := binary.BigEndian.Uint32(p[:4])
@@ -99,6 +271,26 @@ func PutRegPairFloat(p *[4]byte, f float32) {
 	binary.BigEndian.PutUint32(p[:4], bits)
 }
 
+// RegPairInt32 extracts a signed integer from two registers.
+func RegPairInt32(p *[4]byte) int32 {
+	return int32(binary.BigEndian.Uint32(p[:4]))
+}
+
+// PutRegPairInt32 places a signed integer over two registers.
+func PutRegPairInt32(p *[4]byte, v int32) {
+	binary.BigEndian.PutUint32(p[:4], uint32(v))
+}
+
+// RegPairUint32 extracts an unsigned integer from two registers.
+func RegPairUint32(p *[4]byte) uint32 {
+	return binary.BigEndian.Uint32(p[:4])
+}
+
+// PutRegPairUint32 places an unsigned integer over two registers.
+func PutRegPairUint32(p *[4]byte, v uint32) {
+	binary.BigEndian.PutUint32(p[:4], v)
+}
+
 // RegQuadFloat extracts a double-precission floating-point from four registers.
 func RegQuadFloat(p *[8]byte) float64 {
 	bits := binary.BigEndian.Uint64(p[:8])
@@ -110,3 +302,59 @@ func PutRegQuadFloat(p *[8]byte, f float64) {
 	bits := math.Float64bits(f)
 	binary.BigEndian.PutUint64(p[:8], bits)
 }
+
+// RegQuadInt64 extracts a signed integer from four registers.
+func RegQuadInt64(p *[8]byte) int64 {
+	return int64(binary.BigEndian.Uint64(p[:8]))
+}
+
+// PutRegQuadInt64 places a signed integer over four registers.
+func PutRegQuadInt64(p *[8]byte, v int64) {
+	binary.BigEndian.PutUint64(p[:8], uint64(v))
+}
+
+// RegQuadUint64 extracts an unsigned integer from four registers.
+func RegQuadUint64(p *[8]byte) uint64 {
+	return binary.BigEndian.Uint64(p[:8])
+}
+
+// PutRegQuadUint64 places an unsigned integer over four registers.
+func PutRegQuadUint64(p *[8]byte, v uint64) {
+	binary.BigEndian.PutUint64(p[:8], v)
+}
+
+// RegPairFloatWordSwap is like RegPairFloat, yet with the low register first,
+// i.e., in CDAB order.
+func RegPairFloatWordSwap(p *[4]byte) float32 {
+	return math.Float32frombits(RegPairUint32WordSwap(p))
+}
+
+// PutRegPairFloatWordSwap is like PutRegPairFloat, yet with the low register
+// first, i.e., in CDAB order.
+func PutRegPairFloatWordSwap(p *[4]byte, f float32) {
+	PutRegPairUint32WordSwap(p, math.Float32bits(f))
+}
+
+// RegPairInt32WordSwap is like RegPairInt32, yet with the low register first,
+// i.e., in CDAB order.
+func RegPairInt32WordSwap(p *[4]byte) int32 {
+	return int32(RegPairUint32WordSwap(p))
+}
+
+// PutRegPairInt32WordSwap is like PutRegPairInt32, yet with the low register
+// first, i.e., in CDAB order.
+func PutRegPairInt32WordSwap(p *[4]byte, v int32) {
+	PutRegPairUint32WordSwap(p, uint32(v))
+}
+
+// RegPairUint32WordSwap is like RegPairUint32, yet with the low register first,
+// i.e., in CDAB order.
+func RegPairUint32WordSwap(p *[4]byte) uint32 {
+	return bits.RotateLeft32(binary.BigEndian.Uint32(p[:4]), 16)
+}
+
+// PutRegPairUint32WordSwap is like PutRegPairUint32, yet with the low register
+// first, i.e., in CDAB order.
+func PutRegPairUint32WordSwap(p *[4]byte, v uint32) {
+	binary.BigEndian.PutUint32(p[:4], bits.RotateLeft32(v, 16))
+}
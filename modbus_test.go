@@ -0,0 +1,198 @@
+package modbus_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/pascaldekloe/modbus"
+)
+
+func TestRegPairWordSwap(t *testing.T) {
+	p := [4]byte{0x00, 0x00, 0x3f, 0xc0} // 1.5 in CDAB
+	if got := modbus.RegPairFloatWordSwap(&p); got != 1.5 {
+		t.Errorf("got %g, want 1.5", got)
+	}
+	if got := modbus.RegPairInt32WordSwap(&[4]byte{0xff, 0xfe, 0xff, 0xff}); got != -2 {
+		t.Errorf("got %d, want -2", got)
+	}
+
+	var buf [4]byte
+	modbus.PutRegPairUint32WordSwap(&buf, 0x01020304)
+	if want := [4]byte{3, 4, 1, 2}; buf != want {
+		t.Errorf("got %#x, want %#x", buf, want)
+	}
+}
+
+func TestRegOrderCodec(t *testing.T) {
+	tests := []struct {
+		order modbus.RegOrder
+		bytes [4]byte
+	}{
+		{modbus.ABCD, [4]byte{0x3f, 0xc0, 0x00, 0x00}},
+		{modbus.BADC, [4]byte{0xc0, 0x3f, 0x00, 0x00}},
+		{modbus.CDAB, [4]byte{0x00, 0x00, 0x3f, 0xc0}},
+		{modbus.DCBA, [4]byte{0x00, 0x00, 0xc0, 0x3f}},
+	}
+	for _, test := range tests {
+		if got := modbus.DecodeFloat32(test.bytes[:], test.order); got != 1.5 {
+			t.Errorf("%s: got %g, want 1.5", test.order, got)
+		}
+		var buf [4]byte
+		modbus.EncodeFloat32(buf[:], test.order, 1.5)
+		if buf != test.bytes {
+			t.Errorf("%s: encoded %#x, want %#x", test.order, buf, test.bytes)
+		}
+	}
+
+	var buf [8]byte
+	modbus.EncodeInt64(buf[:], modbus.CDAB, -2)
+	if got := modbus.DecodeInt64(buf[:], modbus.CDAB); got != -2 {
+		t.Errorf("int64 round trip got %d, want -2", got)
+	}
+	if want := [8]byte{0xff, 0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}; buf != want {
+		t.Errorf("CDAB int64 encoded %#x, want %#x", buf, want)
+	}
+}
+
+func TestRegQuadInt(t *testing.T) {
+	tests := []struct {
+		order modbus.RegOrder
+		bytes [8]byte
+	}{
+		{modbus.ABCD, [8]byte{0x81, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}},
+		{modbus.BADC, [8]byte{0x02, 0x81, 0x04, 0x03, 0x06, 0x05, 0x08, 0x07}},
+		{modbus.CDAB, [8]byte{0x07, 0x08, 0x05, 0x06, 0x03, 0x04, 0x81, 0x02}},
+		{modbus.DCBA, [8]byte{0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x81}},
+	}
+	const u = 0x8102030405060708
+	const i = -0x7efdfcfbfaf9f8f8 // u as int64
+
+	for _, test := range tests {
+		// register quads are in ABCD
+		var buf [8]byte
+		modbus.PutRegQuadUint64(&buf, u)
+		modbus.EncodeUint64(buf[:], test.order, modbus.RegQuadUint64(&buf))
+		if buf != test.bytes {
+			t.Errorf("%s: uint64 encoded %#x, want %#x", test.order, buf, test.bytes)
+		}
+		modbus.EncodeUint64(buf[:], modbus.ABCD, modbus.DecodeUint64(test.bytes[:], test.order))
+		if got := modbus.RegQuadUint64(&buf); got != u {
+			t.Errorf("%s: uint64 round trip got %#x, want %#x", test.order, got, uint64(u))
+		}
+
+		buf = [8]byte{}
+		modbus.PutRegQuadInt64(&buf, i)
+		modbus.EncodeInt64(buf[:], test.order, modbus.RegQuadInt64(&buf))
+		if buf != test.bytes {
+			t.Errorf("%s: int64 encoded %#x, want %#x", test.order, buf, test.bytes)
+		}
+		modbus.EncodeInt64(buf[:], modbus.ABCD, modbus.DecodeInt64(test.bytes[:], test.order))
+		if got := modbus.RegQuadInt64(&buf); got != i {
+			t.Errorf("%s: int64 round trip got %d, want %d", test.order, got, int64(i))
+		}
+	}
+
+	for _, v := range []int64{0, 1, -1, math.MinInt64, math.MaxInt64} {
+		var buf [8]byte
+		modbus.PutRegQuadInt64(&buf, v)
+		if got := modbus.RegQuadInt64(&buf); got != v {
+			t.Errorf("int64 %d round trip got %d", v, got)
+		}
+	}
+	for _, v := range []uint64{0, 1, math.MaxUint32 + 1, math.MaxUint64} {
+		var buf [8]byte
+		modbus.PutRegQuadUint64(&buf, v)
+		if got := modbus.RegQuadUint64(&buf); got != v {
+			t.Errorf("uint64 %d round trip got %d", v, got)
+		}
+	}
+}
+
+func TestRegSentinel(t *testing.T) {
+	regTests := []struct {
+		r, sentinel uint16
+		valid, ok   bool
+	}{
+		{0, 0xffff, true, true},
+		{0x7fff, 0xffff, true, true},
+		{0xfffe, 0xffff, true, true},
+		{0xffff, 0xffff, false, false},
+		{0x8000, 0x8000, true, false},
+		{0xffff, 0x8000, false, true},
+		{0, 0, true, false},
+	}
+	for _, test := range regTests {
+		v, ok := modbus.RegSentinel(test.r, test.sentinel)
+		if v != test.r || ok != test.ok {
+			t.Errorf("RegSentinel(%#04x, %#04x) got (%#04x, %t), want (%#04x, %t)", test.r, test.sentinel, v, ok, test.r, test.ok)
+		}
+		v, ok = modbus.RegValid(test.r)
+		if v != test.r || ok != test.valid {
+			t.Errorf("RegValid(%#04x) got (%#04x, %t), want (%#04x, %t)", test.r, v, ok, test.r, test.valid)
+		}
+	}
+
+	pairTests := []struct {
+		p         [4]byte
+		want      uint32
+		sentinel  uint32
+		valid, ok bool
+	}{
+		{[4]byte{0, 0, 0, 0}, 0, 0xffffffff, true, true},
+		{[4]byte{0x01, 0x02, 0x03, 0x04}, 0x01020304, 0xffffffff, true, true},
+		{[4]byte{0xff, 0xff, 0xff, 0xfe}, 0xfffffffe, 0xffffffff, true, true},
+		{[4]byte{0x00, 0x00, 0xff, 0xff}, 0xffff, 0xffffffff, true, true},
+		{[4]byte{0xff, 0xff, 0xff, 0xff}, 0xffffffff, 0xffffffff, false, false},
+		{[4]byte{0x80, 0x00, 0x00, 0x00}, 0x80000000, 0x80000000, true, false},
+		{[4]byte{0xff, 0xff, 0xff, 0xff}, 0xffffffff, 0x80000000, false, true},
+	}
+	for _, test := range pairTests {
+		v, ok := modbus.RegPairSentinel(&test.p, test.sentinel)
+		if v != test.want || ok != test.ok {
+			t.Errorf("RegPairSentinel(%#x, %#08x) got (%#08x, %t), want (%#08x, %t)", test.p, test.sentinel, v, ok, test.want, test.ok)
+		}
+		v, ok = modbus.RegPairValid(&test.p)
+		if v != test.want || ok != test.valid {
+			t.Errorf("RegPairValid(%#x) got (%#08x, %t), want (%#08x, %t)", test.p, v, ok, test.want, test.valid)
+		}
+	}
+}
+
+func TestRegBit(t *testing.T) {
+	const r = 0b1010_0000_0110_0001
+	if !modbus.RegBit(r, 0) || modbus.RegBit(r, 1) || !modbus.RegBit(r, 15) {
+		t.Errorf("bits of %#04x misread", r)
+	}
+	if got := modbus.PutRegBit(r, 15, false); got != 0b0010_0000_0110_0001 {
+		t.Errorf("clear bit 15 got %#04x", got)
+	}
+	if got := modbus.PutRegBit(r, 1, true); got != 0b1010_0000_0110_0011 {
+		t.Errorf("set bit 1 got %#04x", got)
+	}
+	if got := modbus.RegBitRange(r, 4, 7); got != 0b0110 {
+		t.Errorf("bits 4–7 got %#b, want 0b110", got)
+	}
+	if got := modbus.RegBitRange(r, 0, 15); got != r {
+		t.Errorf("bits 0–15 got %#04x, want %#04x", got, r)
+	}
+
+	defer func() {
+		if recover() != modbus.ErrBitIndex {
+			t.Error("no ErrBitIndex panic for bit 16")
+		}
+	}()
+	modbus.RegBit(r, 16)
+}
+
+func TestExceptionTemporary(t *testing.T) {
+	for _, e := range []modbus.Exception{modbus.ErrBusy, modbus.ErrAck, modbus.ErrGateTarget} {
+		if !e.Temporary() {
+			t.Errorf("%s not temporary", e)
+		}
+	}
+	for _, e := range []modbus.Exception{modbus.ErrFunc, modbus.ErrAddr, modbus.ErrValue, modbus.ErrDev} {
+		if e.Temporary() {
+			t.Errorf("%s temporary", e)
+		}
+	}
+}
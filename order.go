@@ -0,0 +1,171 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// RegOrder is the byte order of values which span multiple registers. The
+// letters name the bytes of a 32-bit value in big-endian order. ABCD is the
+// Modbus standard. Orders apply to 64-bit values likewise, with CDAB as the
+// reversed register order, and with BADC as the byte swap per register.
+type RegOrder uint8
+
+// Register Orders
+const (
+	ABCD RegOrder = iota // big-endian
+	BADC                 // big-endian with bytes swapped per register
+	CDAB                 // little-endian with bytes swapped per register
+	DCBA                 // little-endian
+)
+
+// String returns the letter notation.
+func (o RegOrder) String() string {
+	switch o {
+	case ABCD:
+		return "ABCD"
+	case BADC:
+		return "BADC"
+	case CDAB:
+		return "CDAB"
+	case DCBA:
+		return "DCBA"
+	}
+	return fmt.Sprintf("RegOrder(%d)", uint8(o))
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (o RegOrder) MarshalText() ([]byte, error) {
+	if o > DCBA {
+		return nil, fmt.Errorf("Modbus register order %d unknown", uint8(o))
+	}
+	return []byte(o.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (o *RegOrder) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "ABCD":
+		*o = ABCD
+	case "BADC":
+		*o = BADC
+	case "CDAB":
+		*o = CDAB
+	case "DCBA":
+		*o = DCBA
+	default:
+		return fmt.Errorf("Modbus register order %q unknown", text)
+	}
+	return nil
+}
+
+// Swap converts between big-endian and o, in place. The byte count of p must
+// be even.
+func (o RegOrder) swap(p []byte) {
+	if o == BADC || o == DCBA {
+		for i := 0; i+1 < len(p); i += 2 {
+			p[i], p[i+1] = p[i+1], p[i]
+		}
+	}
+	if o == CDAB || o == DCBA {
+		for i, j := 0, len(p)-2; i < j; i, j = i+2, j-2 {
+			p[i], p[i+1], p[j], p[j+1] = p[j], p[j+1], p[i], p[i+1]
+		}
+	}
+}
+
+// Uint returns p as an unsigned integer in order o. The byte count of p must
+// be even, and no more than 8.
+func (o RegOrder) uint(p []byte) uint64 {
+	var buf [8]byte
+	b := buf[8-len(p):]
+	copy(b, p)
+	o.swap(b)
+	return binary.BigEndian.Uint64(buf[:])
+}
+
+// PutUint places v in p as an unsigned integer in order o. The byte count of p
+// must be even, and no more than 8. The return is false when v does not fit.
+func (o RegOrder) putUint(p []byte, v uint64) bool {
+	if len(p) < 8 && v>>(8*len(p)) != 0 {
+		return false
+	}
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	copy(p, buf[8-len(p):])
+	o.swap(p)
+	return true
+}
+
+// DecodeUint32 extracts an unsigned integer from two registers in order o. The
+// byte count of p must be at least 4.
+func DecodeUint32(p []byte, o RegOrder) uint32 {
+	return uint32(o.uint(p[:4]))
+}
+
+// EncodeUint32 places an unsigned integer over two registers in order o. The
+// byte count of p must be at least 4.
+func EncodeUint32(p []byte, o RegOrder, v uint32) {
+	o.putUint(p[:4], uint64(v))
+}
+
+// DecodeInt32 extracts a signed integer from two registers in order o. The
+// byte count of p must be at least 4.
+func DecodeInt32(p []byte, o RegOrder) int32 {
+	return int32(DecodeUint32(p, o))
+}
+
+// EncodeInt32 places a signed integer over two registers in order o. The byte
+// count of p must be at least 4.
+func EncodeInt32(p []byte, o RegOrder, v int32) {
+	EncodeUint32(p, o, uint32(v))
+}
+
+// DecodeFloat32 extracts a single-precision floating-point from two registers
+// in order o. The byte count of p must be at least 4.
+func DecodeFloat32(p []byte, o RegOrder) float32 {
+	return math.Float32frombits(DecodeUint32(p, o))
+}
+
+// EncodeFloat32 places a single-precision floating-point over two registers in
+// order o. The byte count of p must be at least 4.
+func EncodeFloat32(p []byte, o RegOrder, f float32) {
+	EncodeUint32(p, o, math.Float32bits(f))
+}
+
+// DecodeUint64 extracts an unsigned integer from four registers in order o.
+// The byte count of p must be at least 8.
+func DecodeUint64(p []byte, o RegOrder) uint64 {
+	return o.uint(p[:8])
+}
+
+// EncodeUint64 places an unsigned integer over four registers in order o. The
+// byte count of p must be at least 8.
+func EncodeUint64(p []byte, o RegOrder, v uint64) {
+	o.putUint(p[:8], v)
+}
+
+// DecodeInt64 extracts a signed integer from four registers in order o. The
+// byte count of p must be at least 8.
+func DecodeInt64(p []byte, o RegOrder) int64 {
+	return int64(DecodeUint64(p, o))
+}
+
+// EncodeInt64 places a signed integer over four registers in order o. The byte
+// count of p must be at least 8.
+func EncodeInt64(p []byte, o RegOrder, v int64) {
+	EncodeUint64(p, o, uint64(v))
+}
+
+// DecodeFloat64 extracts a double-precision floating-point from four registers
+// in order o. The byte count of p must be at least 8.
+func DecodeFloat64(p []byte, o RegOrder) float64 {
+	return math.Float64frombits(DecodeUint64(p, o))
+}
+
+// EncodeFloat64 places a double-precision floating-point over four registers
+// in order o. The byte count of p must be at least 8.
+func EncodeFloat64(p []byte, o RegOrder, f float64) {
+	EncodeUint64(p, o, math.Float64bits(f))
+}
@@ -0,0 +1,225 @@
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// PipelineClient allows for multiple outstanding requests on a connection.
+// Responses are matched to their request by transaction identifier, in any
+// order. The device must support concurrent transactions. All methods are safe
+// for concurrent use.
+type PipelineClient struct {
+	// Transport is the PipelineClient itself.
+	Client
+
+	// Limit the time for a request–response pair. The zero value omits
+	// timeout protection. Set before use.
+	TxTimeout time.Duration
+
+	conn   net.Conn
+	unitID byte
+
+	writeMutex sync.Mutex // serializes frame submission
+
+	mutex sync.Mutex // guards the fields below
+	// transaction identifier sequence
+	txN uint16
+	// awaiting response per transaction identifier
+	pending map[uint16]chan pipelineResult
+	// fatal to the connection, if any
+	err error
+	// set by CloseContext
+	closing bool
+	// closed once pending drains, if set
+	idle chan struct{}
+}
+
+type pipelineResult struct {
+	res []byte // PDU data
+	err error
+}
+
+// NewPipelineClient starts a reader on conn. Use Close to stop.
+func NewPipelineClient(conn net.Conn, unitID byte) *PipelineClient {
+	p := &PipelineClient{
+		conn:    conn,
+		unitID:  unitID,
+		pending: make(map[uint16]chan pipelineResult),
+	}
+	p.Client = Client{p}
+	go p.readLoop()
+	return p
+}
+
+// Close terminates the connection. Transactions in progress get an error.
+func (p *PipelineClient) Close() error {
+	return p.conn.Close()
+}
+
+// CloseContext terminates the connection once the transactions in progress
+// are done, or when ctx expires, whichever comes first. New transactions get
+// net.ErrClosed in the mean time. The return is ctx.Err() on expiry.
+func (p *PipelineClient) CloseContext(ctx context.Context) error {
+	p.mutex.Lock()
+	p.closing = true
+	var idle chan struct{}
+	if len(p.pending) != 0 {
+		if p.idle == nil {
+			p.idle = make(chan struct{})
+		}
+		idle = p.idle
+	}
+	p.mutex.Unlock()
+
+	if idle != nil {
+		select {
+		case <-idle:
+			break
+		case <-ctx.Done():
+			p.conn.Close()
+			return ctx.Err()
+		}
+	}
+	return p.conn.Close()
+}
+
+// Drop removes a transaction from pending. The mutex must be held.
+func (p *PipelineClient) drop(txID uint16) {
+	delete(p.pending, txID)
+	if p.idle != nil && len(p.pending) == 0 {
+		close(p.idle)
+		p.idle = nil
+	}
+}
+
+// Exchange implements the Transport interface. The response is not shared,
+// i.e., it remains valid after subsequent use.
+func (p *PipelineClient) Exchange(funcCode byte, req []byte) ([]byte, error) {
+	if 1+len(req) > MaxPDUSize {
+		return nil, ErrLimit
+	}
+
+	ch := make(chan pipelineResult, 1)
+	p.mutex.Lock()
+	if p.err != nil {
+		p.mutex.Unlock()
+		return nil, p.err
+	}
+	if p.closing {
+		p.mutex.Unlock()
+		return nil, fmt.Errorf("Modbus pipeline closing: %w", net.ErrClosed)
+	}
+	if len(p.pending) > 0xffff {
+		p.mutex.Unlock()
+		return nil, ErrLimit
+	}
+	for {
+		p.txN++
+		if _, ok := p.pending[p.txN]; !ok {
+			break
+		}
+	}
+	txID := p.txN
+	p.pending[txID] = ch
+	p.mutex.Unlock()
+
+	frame := make([]byte, 8+len(req))
+	// See “MBAP Header description” from chapter 3.1.3 of “MODBUS
+	// Messaging on TCP/IP Implementation Guide V1.0b” for the layout.
+	binary.BigEndian.PutUint16(frame[0:2], txID)
+	binary.BigEndian.PutUint16(frame[4:6], uint16(len(frame)-6))
+	frame[6] = p.unitID
+	frame[7] = funcCode
+	copy(frame[8:], req)
+
+	p.writeMutex.Lock()
+	_, err := p.conn.Write(frame)
+	p.writeMutex.Unlock()
+	if err != nil {
+		p.conn.Close() // reader cleans up
+		return nil, fmt.Errorf("Modbus request submission: %w", err)
+	}
+
+	var timeout <-chan time.Time
+	if p.TxTimeout != 0 {
+		timer := time.NewTimer(p.TxTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case r := <-ch:
+		if r.err != nil {
+			return nil, r.err
+		}
+		switch r.res[0] {
+		case funcCode:
+			return r.res[1:], nil
+		case funcCode | errorFlag:
+			if len(r.res) != 2 {
+				return nil, errFrameFit
+			}
+			return nil, Exception(r.res[1])
+		}
+		return nil, fmt.Errorf("Modbus response function code %#02x does not match request function code %#02x",
+			r.res[0], funcCode)
+
+	case <-timeout:
+		p.mutex.Lock()
+		p.drop(txID)
+		p.mutex.Unlock()
+		return nil, fmt.Errorf("Modbus response unavailable: %w", os.ErrDeadlineExceeded)
+	}
+}
+
+// ReadLoop dispatches responses until the connection fails.
+func (p *PipelineClient) readLoop() {
+	var head [7]byte
+	var err error
+	for {
+		_, err = io.ReadFull(p.conn, head[:])
+		if err != nil {
+			break
+		}
+		size := int(binary.BigEndian.Uint16(head[4:6]))
+		if binary.BigEndian.Uint16(head[2:4]) != 0 || head[6] != p.unitID || size < 2 || size > 254 {
+			err = fmt.Errorf("Modbus response header %#x does not match the requests", head)
+			break
+		}
+		pdu := make([]byte, size-1)
+		_, err = io.ReadFull(p.conn, pdu)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			break
+		}
+
+		txID := binary.BigEndian.Uint16(head[0:2])
+		p.mutex.Lock()
+		ch, ok := p.pending[txID]
+		p.drop(txID)
+		p.mutex.Unlock()
+		if ok {
+			ch <- pipelineResult{res: pdu}
+		}
+		// responses after timeout are discarded
+	}
+
+	p.conn.Close()
+	err = fmt.Errorf("Modbus response unavailable: %w", err)
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.err = err
+	for txID, ch := range p.pending {
+		ch <- pipelineResult{err: err}
+		p.drop(txID)
+	}
+}
@@ -0,0 +1,140 @@
+package modbus_test
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pascaldekloe/modbus"
+)
+
+func TestPipelineClient(t *testing.T) {
+	clientEnd, deviceEnd := net.Pipe()
+	p := modbus.NewPipelineClient(clientEnd, 1)
+	p.TxTimeout = time.Second
+	defer p.Close()
+
+	// device responds to each pair of requests in reverse order, with
+	// registers that hold their address
+	go func() {
+		for {
+			var reqs [2][12]byte
+			for i := range reqs {
+				if _, err := io.ReadFull(deviceEnd, reqs[i][:]); err != nil {
+					return
+				}
+			}
+			for i := len(reqs) - 1; i >= 0; i-- {
+				res := append([]byte(nil), reqs[i][:8]...)
+				res = append(res, 2, reqs[i][8], reqs[i][9])
+				binary.BigEndian.PutUint16(res[4:6], uint16(len(res)-6))
+				if _, err := deviceEnd.Write(res); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for addr := uint16(i * 1000); addr < uint16(i*1000+20); addr++ {
+				got, err := p.ReadHoldReg(addr)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if got != addr {
+					t.Errorf("got register %d value %d", addr, got)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	deviceEnd.Close()
+	if _, err := p.ReadHoldReg(1); !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("got error %v after device close, want EOF", err)
+	}
+}
+
+func TestPipelineClientCloseContext(t *testing.T) {
+	tests := []struct {
+		name    string
+		respond bool
+		wantErr error
+	}{
+		{"drain", true, nil},
+		{"expire", false, context.DeadlineExceeded},
+	}
+	for _, test := range tests {
+		clientEnd, deviceEnd := net.Pipe()
+		p := modbus.NewPipelineClient(clientEnd, 1)
+
+		reqs := make(chan []byte)
+		go func() {
+			req := make([]byte, 12)
+			if _, err := io.ReadFull(deviceEnd, req); err == nil {
+				reqs <- req
+			}
+		}()
+
+		type result struct {
+			value uint16
+			err   error
+		}
+		results := make(chan result)
+		go func() {
+			v, err := p.ReadHoldReg(7)
+			results <- result{v, err}
+		}()
+		req := <-reqs
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		closed := make(chan error)
+		go func() {
+			closed <- p.CloseContext(ctx)
+		}()
+		time.Sleep(10 * time.Millisecond)
+
+		select {
+		case err := <-closed:
+			t.Fatalf("%s: close returned %v with a transaction in progress", test.name, err)
+		default:
+			break
+		}
+		if _, err := p.ReadHoldReg(8); !errors.Is(err, net.ErrClosed) {
+			t.Errorf("%s: got error %v for new transaction during close, want net.ErrClosed", test.name, err)
+		}
+
+		if test.respond {
+			res := append(req[:8:8], 2, 0, 42)
+			binary.BigEndian.PutUint16(res[4:6], uint16(len(res)-6))
+			if _, err := deviceEnd.Write(res); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := <-closed; err != test.wantErr {
+			t.Errorf("%s: got close error %v, want %v", test.name, err, test.wantErr)
+		}
+		r := <-results
+		switch {
+		case test.respond && r.err != nil:
+			t.Errorf("%s: in-flight read error: %s", test.name, r.err)
+		case test.respond && r.value != 42:
+			t.Errorf("%s: in-flight read got %d, want 42", test.name, r.value)
+		case !test.respond && r.err == nil:
+			t.Errorf("%s: in-flight read got no error", test.name)
+		}
+		cancel()
+		deviceEnd.Close()
+	}
+}
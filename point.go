@@ -0,0 +1,342 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// PointType is the encoding of a Point value.
+type PointType string
+
+// Point Types
+const (
+	Uint16  PointType = "uint16"
+	Int16   PointType = "int16"
+	Uint32  PointType = "uint32"
+	Int32   PointType = "int32"
+	Float32 PointType = "float32"
+	Uint64  PointType = "uint64"
+	Int64   PointType = "int64"
+	Float64 PointType = "float64"
+)
+
+// RegN returns the number of registers needed, with zero for unknown types.
+func (t PointType) regN() int {
+	switch t {
+	case Uint16, Int16:
+		return 1
+	case Uint32, Int32, Float32:
+		return 2
+	case Uint64, Int64, Float64:
+		return 4
+	}
+	return 0
+}
+
+// Point is a register value at a fixed address. State is kept from the last
+// successful Read. A Point must not be copied after first use.
+type Point struct {
+	Name  string // optional label
+	Addr  uint16 // first register
+	N     int    // register count for the zero Type, with zero for one
+	Input bool   // input register instead of holding register
+
+	// The zero Type reads as an unsigned integer of N registers.
+	Type PointType
+	// Order applies to types of multiple registers.
+	Order RegOrder
+
+	// Optional conversion to engineering units.
+	Scale *LinearScale
+
+	// StaleOnError makes Read retain the last value on failure, i.e., the
+	// error is dropped in favour of the Stale flag.
+	StaleOnError bool
+
+	// Optional raw value, as an unsigned integer, which means no valid
+	// reading, e.g., 0xFFFF.
+	Sentinel *uint64
+
+	// Optional accumulation of the raw value, as an unsigned integer.
+	Counter *Rollover
+
+	// Track enables the Extremes statistics.
+	Track bool
+
+	// Registers from the last successful Read (read-only).
+	Raw []uint16
+	// Time of the last successful Read (read-only).
+	Time time.Time
+	// Stale is set when a Read failed with StaleOnError (read-only).
+	Stale bool
+	// Err has the cause of Stale, if any (read-only).
+	Err error
+
+	mutex    sync.Mutex // guards extremes
+	extremes Extremes
+}
+
+// Extremes has statistics on values read.
+type Extremes struct {
+	Min, Max, Last float64
+	N              int       // number of values
+	Since          time.Time // start of the window
+}
+
+// Read fetches the registers into Raw. Errors are suppressed on StaleOnError
+// after any successful Read.
+func (p *Point) Read(c *TCPClient) error {
+	n := p.Type.regN()
+	if n == 0 {
+		n = max(p.N, 1)
+	}
+	if len(p.Raw) != n {
+		p.Raw = make([]uint16, n)
+		p.Time = time.Time{}
+	}
+
+	funcCode := byte(readHoldRegs)
+	if p.Input {
+		funcCode = readInputRegs
+	}
+	// buffer remains untouched on error
+	err := c.readRegs(p.Raw, p.Addr, funcCode)
+	if err != nil {
+		if !p.StaleOnError || p.Time.IsZero() {
+			return err
+		}
+		p.Stale, p.Err = true, err
+		return nil
+	}
+
+	p.Time = time.Now()
+	p.Stale, p.Err = false, nil
+
+	if p.Counter != nil {
+		p.Counter.Update(p.rawUint())
+	}
+
+	if p.Track {
+		v := p.Value()
+		p.mutex.Lock()
+		e := &p.extremes
+		if e.N == 0 {
+			e.Min, e.Max = v, v
+			if e.Since.IsZero() {
+				e.Since = p.Time
+			}
+		} else {
+			e.Min, e.Max = min(e.Min, v), max(e.Max, v)
+		}
+		e.Last = v
+		e.N++
+		p.mutex.Unlock()
+	}
+	return nil
+}
+
+// Extremes returns a snapshot of the statistics from Track. The method is safe
+// for concurrent use.
+func (p *Point) Extremes() Extremes {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.extremes
+}
+
+// ResetExtremes starts a new window for the statistics from Track. The method
+// is safe for concurrent use.
+func (p *Point) ResetExtremes() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.extremes = Extremes{Since: time.Now()}
+}
+
+// Age returns the time passed since the last successful Read.
+func (p *Point) Age() time.Duration {
+	return time.Since(p.Time)
+}
+
+// Value returns the registers from the last successful Read conform Type and
+// Order, with Scale applied, if any.
+func (p *Point) Value() float64 {
+	var v float64
+	if n := p.Type.regN(); n == 0 || len(p.Raw) != n {
+		var u uint64
+		for _, r := range p.Raw {
+			u = u<<16 | uint64(r)
+		}
+		v = float64(u)
+	} else {
+		var buf [8]byte
+		b := buf[:2*n]
+		for i, r := range p.Raw {
+			binary.BigEndian.PutUint16(b[2*i:], r)
+		}
+		p.Order.swap(b)
+
+		switch p.Type {
+		case Uint16:
+			v = float64(binary.BigEndian.Uint16(b))
+		case Int16:
+			v = float64(int16(binary.BigEndian.Uint16(b)))
+		case Uint32:
+			v = float64(binary.BigEndian.Uint32(b))
+		case Int32:
+			v = float64(int32(binary.BigEndian.Uint32(b)))
+		case Float32:
+			v = float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+		case Uint64:
+			v = float64(binary.BigEndian.Uint64(b))
+		case Int64:
+			v = float64(int64(binary.BigEndian.Uint64(b)))
+		case Float64:
+			v = math.Float64frombits(binary.BigEndian.Uint64(b))
+		}
+	}
+
+	if p.Scale != nil {
+		v = p.Scale.Apply(v)
+	}
+	return v
+}
+
+// ValueOK is like Value, with ok false when the registers hold the Sentinel.
+func (p *Point) ValueOK() (v float64, ok bool) {
+	if p.Sentinel != nil && *p.Sentinel == p.rawUint() {
+		return 0, false
+	}
+	return p.Value(), true
+}
+
+// RawUint returns the registers from the last successful Read, as an unsigned
+// integer conform Order.
+func (p *Point) rawUint() uint64 {
+	var buf [8]byte
+	b := buf[:2*min(len(p.Raw), 4)]
+	for i := range b {
+		b[i] = byte(p.Raw[i/2] >> (8 - i%2*8))
+	}
+	return p.Order.uint(b)
+}
+
+// Reading has a point value together with its registers.
+type Reading struct {
+	Raw   []uint16 // copy
+	Value float64
+}
+
+// ReadDetail does a Read, and it returns both the registers and the Value,
+// which may be Stale.
+func (p *Point) ReadDetail(c *TCPClient) (Reading, error) {
+	err := p.Read(c)
+	if err != nil {
+		return Reading{}, err
+	}
+	return Reading{Raw: slices.Clone(p.Raw), Value: p.Value()}, nil
+}
+
+// Quality classifies a Sample.
+type Quality uint8
+
+// Sample Qualities
+const (
+	Good      Quality = iota // fresh value
+	Uncertain                // device reports no valid reading with Sentinel
+	Stale                    // last-known-good value after a failed read
+	Bad                      // value unavailable
+)
+
+// String returns the name in lower case.
+func (q Quality) String() string {
+	switch q {
+	case Good:
+		return "good"
+	case Uncertain:
+		return "uncertain"
+	case Stale:
+		return "stale"
+	case Bad:
+		return "bad"
+	}
+	return fmt.Sprintf("Quality(%d)", uint8(q))
+}
+
+// Sample is a point value with its quality.
+type Sample struct {
+	Value   float64 // NaN when Uncertain or Bad
+	Quality Quality
+	Time    time.Time // of the read
+}
+
+// ReadSample does a Read, and it returns the outcome as a Sample. Stale needs
+// StaleOnError set. The cause of Stale is available as Err.
+func (p *Point) ReadSample(c *TCPClient) Sample {
+	err := p.Read(c)
+	switch {
+	case err != nil:
+		return Sample{Value: math.NaN(), Quality: Bad, Time: time.Now()}
+	case p.Stale:
+		return Sample{Value: p.Value(), Quality: Stale, Time: p.Time}
+	}
+	v, ok := p.ValueOK()
+	if !ok {
+		return Sample{Value: math.NaN(), Quality: Uncertain, Time: p.Time}
+	}
+	return Sample{Value: v, Quality: Good, Time: p.Time}
+}
+
+// LoadPointMap reads point definitions from a JSON array. Each object has an
+// "addr", and optionally a "name", a "type" (default "uint16"), an "order"
+// (default "ABCD"), a "scale" (default 1), an "offset" (default 0), and an
+// "input" flag for input registers instead of holding registers.
+func LoadPointMap(r io.Reader) ([]*Point, error) {
+	var entries []struct {
+		Name   string    `json:"name"`
+		Addr   *uint16   `json:"addr"`
+		Type   PointType `json:"type"`
+		Order  RegOrder  `json:"order"`
+		Scale  *float64  `json:"scale"`
+		Offset float64   `json:"offset"`
+		Input  bool      `json:"input"`
+	}
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&entries); err != nil {
+		return nil, fmt.Errorf("Modbus point map: %w", err)
+	}
+
+	points := make([]*Point, len(entries))
+	for i, e := range entries {
+		if e.Addr == nil {
+			return nil, fmt.Errorf("Modbus point map entry %d has no address", i)
+		}
+		if e.Type == "" {
+			e.Type = Uint16
+		}
+		if e.Type.regN() == 0 {
+			return nil, fmt.Errorf("Modbus point map entry %d has unknown type %q", i, e.Type)
+		}
+
+		points[i] = &Point{
+			Name:  e.Name,
+			Addr:  *e.Addr,
+			Input: e.Input,
+			Type:  e.Type,
+			Order: e.Order,
+		}
+		if e.Scale != nil || e.Offset != 0 {
+			s := LinearScale{Scale: 1, Offset: e.Offset}
+			if e.Scale != nil {
+				s.Scale = *e.Scale
+			}
+			points[i].Scale = &s
+		}
+	}
+	return points, nil
+}
@@ -0,0 +1,150 @@
+package modbus
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestPointStaleOnError(t *testing.T) {
+	for _, staleOnError := range []bool{false, true} {
+		regs := [2]uint16{7, 8}
+		var fail Exception
+		c := TCPClient{
+			Conn: &fakeDevice{respond: func(req []byte) []byte {
+				if fail != 0 {
+					frame := responseFrame(req, byte(fail))
+					frame[7] |= errorFlag
+					return frame
+				}
+				return responseFrame(req, 4, byte(regs[0]>>8), byte(regs[0]), byte(regs[1]>>8), byte(regs[1]))
+			}},
+			UnitID: 1,
+		}
+		p := Point{Addr: 10, N: 2, StaleOnError: staleOnError}
+
+		// no value to fall back to
+		fail = ErrDev
+		if err := p.Read(&c); !errors.Is(err, ErrDev) {
+			t.Errorf("StaleOnError %t: got error %v on first read, want ErrDev", staleOnError, err)
+		}
+
+		fail = 0
+		if err := p.Read(&c); err != nil {
+			t.Fatalf("StaleOnError %t: read error: %s", staleOnError, err)
+		}
+		readTime := p.Time
+
+		fail = ErrDev
+		regs[0] = 9
+		err := p.Read(&c)
+		if !staleOnError {
+			if !errors.Is(err, ErrDev) || p.Stale {
+				t.Errorf("StaleOnError false: got error %v with Stale %t, want ErrDev without Stale", err, p.Stale)
+			}
+			continue
+		}
+		if err != nil || !p.Stale || !errors.Is(p.Err, ErrDev) {
+			t.Errorf("StaleOnError true: got error %v with Stale %t and Err %v, want Stale with ErrDev", err, p.Stale, p.Err)
+		}
+		if p.Raw[0] != 7 || p.Raw[1] != 8 || !p.Time.Equal(readTime) {
+			t.Errorf("StaleOnError true: got registers %d at %s, want last-known-good [7 8] at %s", p.Raw, p.Time, readTime)
+		}
+
+		// recovery
+		fail = 0
+		if err := p.Read(&c); err != nil || p.Stale || p.Err != nil || p.Raw[0] != 9 {
+			t.Errorf("StaleOnError true: got (%v, Stale %t, Err %v, registers %d) after recovery, want fresh register 9", err, p.Stale, p.Err, p.Raw)
+		}
+	}
+}
+
+func TestPointReadSample(t *testing.T) {
+	var reg uint16
+	var fail Exception
+	c := TCPClient{
+		Conn: &fakeDevice{respond: func(req []byte) []byte {
+			if fail != 0 {
+				frame := responseFrame(req, byte(fail))
+				frame[7] |= errorFlag
+				return frame
+			}
+			return responseFrame(req, 2, byte(reg>>8), byte(reg))
+		}},
+		UnitID: 1,
+	}
+	sentinel := uint64(0xffff)
+	p := Point{Addr: 3, Sentinel: &sentinel, StaleOnError: true}
+
+	// no value yet
+	fail = ErrDev
+	if s := p.ReadSample(&c); s.Quality != Bad || !math.IsNaN(s.Value) {
+		t.Errorf("got %+v without any value, want Bad with NaN", s)
+	}
+
+	fail = 0
+	reg = 7
+	if s := p.ReadSample(&c); s.Quality != Good || s.Value != 7 || !s.Time.Equal(p.Time) {
+		t.Errorf("got %+v, want Good 7", s)
+	}
+
+	reg = 0xffff
+	if s := p.ReadSample(&c); s.Quality != Uncertain || !math.IsNaN(s.Value) {
+		t.Errorf("got %+v for sentinel, want Uncertain with NaN", s)
+	}
+
+	reg = 9
+	p.ReadSample(&c)
+	fail = ErrDev
+	if s := p.ReadSample(&c); s.Quality != Stale || s.Value != 9 || !s.Time.Equal(p.Time) {
+		t.Errorf("got %+v on failure, want Stale 9", s)
+	}
+
+	p.StaleOnError = false
+	if s := p.ReadSample(&c); s.Quality != Bad || !math.IsNaN(s.Value) {
+		t.Errorf("got %+v on failure without StaleOnError, want Bad with NaN", s)
+	}
+}
+
+func TestPointReadDetail(t *testing.T) {
+	regs := [2]uint16{0x0000, 0x3fc0}
+	var fail Exception
+	c := TCPClient{
+		Conn: &fakeDevice{respond: func(req []byte) []byte {
+			if fail != 0 || req[7] != readInputRegs {
+				frame := responseFrame(req, byte(ErrDev))
+				frame[7] |= errorFlag
+				return frame
+			}
+			return responseFrame(req, 4, byte(regs[0]>>8), byte(regs[0]), byte(regs[1]>>8), byte(regs[1]))
+		}},
+		UnitID: 1,
+	}
+	p := Point{Addr: 20, Input: true, Type: Float32, Order: CDAB,
+		Scale: &LinearScale{Scale: 2}}
+
+	r, err := p.ReadDetail(&c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Value != 3 {
+		t.Errorf("got value %f, want 1.5 scaled to 3", r.Value)
+	}
+	if len(r.Raw) != 2 || r.Raw[0] != 0x0000 || r.Raw[1] != 0x3fc0 {
+		t.Errorf("got registers %#04x, want [0x0000 0x3fc0]", r.Raw)
+	}
+
+	// copy not affected by subsequent reads
+	regs[0] = 0x1234
+	if _, err := p.ReadDetail(&c); err != nil {
+		t.Fatal(err)
+	}
+	if r.Raw[0] != 0x0000 {
+		t.Errorf("got register %#04x after subsequent read, want 0x0000", r.Raw[0])
+	}
+
+	fail = ErrDev
+	if _, err := p.ReadDetail(&c); !errors.Is(err, ErrDev) {
+		t.Errorf("got error %v, want ErrDev", err)
+	}
+}
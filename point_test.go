@@ -0,0 +1,69 @@
+package modbus_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pascaldekloe/modbus"
+)
+
+func TestLoadPointMap(t *testing.T) {
+	points, err := modbus.LoadPointMap(strings.NewReader(`[
+		{"name": "flow", "addr": 1001, "type": "float32", "order": "CDAB"},
+		{"name": "temp", "addr": 7, "type": "int16", "scale": 0.1, "offset": -40, "input": true}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 2 {
+		t.Fatalf("got %d points, want 2", len(points))
+	}
+
+	flow := points[0]
+	flow.Raw = []uint16{0x0000, 0x3fc0} // 1.5 word-swapped
+	if got := flow.Value(); got != 1.5 {
+		t.Errorf("flow got %f, want 1.5", got)
+	}
+
+	temp := points[1]
+	if !temp.Input {
+		t.Error("temp is not an input register")
+	}
+	temp.Raw = []uint16{0xffec} // -20
+	if got := temp.Value(); got != -42 {
+		t.Errorf("temp got %f, want -42", got)
+	}
+}
+
+func TestLoadPointMapValidation(t *testing.T) {
+	tests := []string{
+		`[{"name": "no address"}]`,
+		`[{"addr": 1, "type": "int24"}]`,
+		`[{"addr": 1, "order": "ACBD"}]`,
+		`[{"addr": 1, "unit": "°C"}]`,
+	}
+	for _, json := range tests {
+		_, err := modbus.LoadPointMap(strings.NewReader(json))
+		if err == nil {
+			t.Errorf("%s: no error", json)
+		}
+	}
+}
+
+func TestQualityString(t *testing.T) {
+	tests := []struct {
+		q    modbus.Quality
+		want string
+	}{
+		{modbus.Good, "good"},
+		{modbus.Uncertain, "uncertain"},
+		{modbus.Stale, "stale"},
+		{modbus.Bad, "bad"},
+		{9, "Quality(9)"},
+	}
+	for _, test := range tests {
+		if got := test.q.String(); got != test.want {
+			t.Errorf("got %q for %d, want %q", got, test.q, test.want)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package modbus
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ReadBlock is a range of consecutive addresses to read.
+type ReadBlock struct {
+	// Either 0x01 for coils, 0x02 for discrete inputs, 0x03 for holding
+	// registers, or 0x04 for input registers.
+	FuncCode byte
+
+	Addr uint16 // start address
+	N    int    // number of addresses
+}
+
+// BlockResult is the outcome of a ReadBlock.
+type BlockResult struct {
+	Block ReadBlock
+	Regs  []uint16 // function codes 0x03 and 0x04
+	Bits  []bool   // function codes 0x01 and 0x02
+	Err   error
+	Time  time.Time // of the read
+}
+
+// Poll reads blocks each interval until ctx is done, starting right away.
+// Blocks with the same function code are merged into fewer transactions when
+// adjacent or overlapping. The callback gets each block in order of the blocks
+// slice. The slices in BlockResult are not shared. The return is ctx.Err(),
+// or an error without any reads for an interval less than or equal to zero.
+func (c *TCPClient) Poll(ctx context.Context, interval time.Duration, blocks []ReadBlock, cb func(BlockResult)) error {
+	if interval <= 0 {
+		return fmt.Errorf("Modbus poll interval %s not positive", interval)
+	}
+	err := checkBlocks(blocks)
+	if err != nil {
+		return err
+	}
+	plan := mergeBlocks(blocks)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		c.WithContext(ctx, func() error {
+			c.pollOnce(blocks, plan, cb)
+			return nil
+		})
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			break
+		}
+	}
+}
+
+// ReadBlocks reads each block once, in the fewest transactions, conform Poll.
+// The results are in order of the blocks slice. Coils, discrete inputs, and
+// the two types of registers each have their own address space, so merges
+// only apply to blocks with the same function code. Errors are per block.
+func (c *TCPClient) ReadBlocks(blocks []ReadBlock) ([]BlockResult, error) {
+	err := checkBlocks(blocks)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]BlockResult, 0, len(blocks))
+	c.pollOnce(blocks, mergeBlocks(blocks), func(r BlockResult) {
+		results = append(results, r)
+	})
+	return results, nil
+}
+
+// CheckBlocks validates blocks for Poll and ReadBlocks.
+func checkBlocks(blocks []ReadBlock) error {
+	for _, b := range blocks {
+		switch b.FuncCode {
+		case readCoils, readDiscreteInputs, readHoldRegs, readInputRegs:
+			break
+		default:
+			return fmt.Errorf("Modbus read block with function code %#02x not supported", b.FuncCode)
+		}
+		if b.N <= 0 {
+			return fmt.Errorf("Modbus read block of %d addresses at %d out of range", b.N, b.Addr)
+		}
+		if err := checkAddrRange(b.Addr, b.N); err != nil {
+			return fmt.Errorf("Modbus read block of %d addresses at %d: %w", b.N, b.Addr, err)
+		}
+	}
+	return nil
+}
+
+// MergeBlocks returns the transactions needed for blocks. The address ranges
+// in return are disjoint.
+func mergeBlocks(blocks []ReadBlock) []ReadBlock {
+	sorted := slices.Clone(blocks)
+	slices.SortFunc(sorted, func(a, b ReadBlock) int {
+		if a.FuncCode != b.FuncCode {
+			return int(a.FuncCode) - int(b.FuncCode)
+		}
+		return int(a.Addr) - int(b.Addr)
+	})
+
+	var plan []ReadBlock
+	for _, b := range sorted {
+		limit := 125
+		if b.FuncCode == readCoils || b.FuncCode == readDiscreteInputs {
+			limit = 2000
+		}
+
+		if n := len(plan); n != 0 && plan[n-1].FuncCode == b.FuncCode {
+			last := &plan[n-1]
+			lastEnd := int(last.Addr) + last.N
+			end := max(lastEnd, int(b.Addr)+b.N)
+			if int(b.Addr) <= lastEnd && end-int(last.Addr) <= limit {
+				last.N = end - int(last.Addr)
+				continue
+			}
+			if int(b.Addr) < lastEnd {
+				// read the overlap only once
+				b.N = end - lastEnd
+				b.Addr = uint16(lastEnd)
+			}
+		}
+		// split blocks over the limit
+		for offset := 0; offset < b.N; offset += limit {
+			plan = append(plan, ReadBlock{b.FuncCode, b.Addr + uint16(offset), min(b.N-offset, limit)})
+		}
+	}
+	return plan
+}
+
+// PollOnce reads the plan, and it reports each block.
+func (c *TCPClient) pollOnce(blocks, plan []ReadBlock, cb func(BlockResult)) {
+	regs := make([][]uint16, len(plan))
+	bits := make([][]bool, len(plan))
+	errs := make([]error, len(plan))
+	times := make([]time.Time, len(plan))
+	for i, p := range plan {
+		if p.FuncCode == readCoils || p.FuncCode == readDiscreteInputs {
+			bits[i] = make([]bool, p.N)
+			errs[i] = c.readBools(bits[i], p.Addr, p.FuncCode)
+		} else {
+			regs[i] = make([]uint16, p.N)
+			errs[i] = c.readRegs(regs[i], p.Addr, p.FuncCode)
+		}
+		times[i] = time.Now()
+	}
+
+	for _, b := range blocks {
+		r := BlockResult{Block: b}
+		end := int(b.Addr) + b.N
+		for i, p := range plan {
+			if p.FuncCode != b.FuncCode || int(p.Addr)+p.N <= int(b.Addr) || int(p.Addr) >= end {
+				continue // no overlap
+			}
+			if errs[i] != nil {
+				r.Err = errs[i]
+				break
+			}
+			r.Time = times[i]
+			from := max(int(b.Addr), int(p.Addr))
+			to := min(end, int(p.Addr)+p.N)
+			if bits[i] != nil {
+				r.Bits = append(r.Bits, bits[i][from-int(p.Addr):to-int(p.Addr)]...)
+			} else {
+				r.Regs = append(r.Regs, regs[i][from-int(p.Addr):to-int(p.Addr)]...)
+			}
+		}
+		if r.Err != nil {
+			r.Regs, r.Bits = nil, nil
+		}
+		cb(r)
+	}
+}
+
+// ReadRange is a span of consecutive registers.
+type ReadRange struct {
+	Addr uint16 // start address
+	N    int    // number of registers
+}
+
+// CoalesceReads groups register addresses into ranges, in ascending order.
+// Addresses separated by less than maxGap unused registers share a range, and
+// so do adjacent addresses. Ranges do not exceed 125 registers, conform
+// ReadHoldRegs.
+func CoalesceReads(addrs []uint16, maxGap int) []ReadRange {
+	sorted := slices.Clone(addrs)
+	slices.Sort(sorted)
+	sorted = slices.Compact(sorted)
+
+	var ranges []ReadRange
+	for _, addr := range sorted {
+		if n := len(ranges); n != 0 {
+			last := &ranges[n-1]
+			gap := int(addr) - (int(last.Addr) + last.N)
+			if (gap == 0 || gap < maxGap) && int(addr)-int(last.Addr) < 125 {
+				last.N = int(addr) - int(last.Addr) + 1
+				continue
+			}
+		}
+		ranges = append(ranges, ReadRange{Addr: addr, N: 1})
+	}
+	return ranges
+}
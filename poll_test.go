@@ -0,0 +1,204 @@
+package modbus_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/pascaldekloe/modbus"
+)
+
+func TestPoll(t *testing.T) {
+	server, client := testServerClient(t)
+	for addr := uint16(100); addr < 120; addr++ {
+		server.SetHoldReg(addr, addr)
+	}
+	server.SetCoil(5, true)
+
+	blocks := []modbus.ReadBlock{
+		{FuncCode: 0x03, Addr: 110, N: 4},
+		{FuncCode: 0x01, Addr: 4, N: 2},
+		{FuncCode: 0x03, Addr: 100, N: 12},
+	}
+	var results []modbus.BlockResult
+	ctx, cancel := context.WithCancel(context.Background())
+	err := client.Poll(ctx, time.Hour, blocks, func(r modbus.BlockResult) {
+		results = append(results, r)
+		if len(results) == len(blocks) {
+			cancel()
+		}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got poll error %v, want context.Canceled", err)
+	}
+
+	if len(results) != len(blocks) {
+		t.Fatalf("got %d results, want %d", len(results), len(blocks))
+	}
+	for i, r := range results {
+		if r.Err != nil {
+			t.Errorf("block %d error: %s", i, r.Err)
+		}
+		if r.Block != blocks[i] {
+			t.Errorf("result %d got block %+v, want %+v", i, r.Block, blocks[i])
+		}
+	}
+	if want := []uint16{110, 111, 112, 113}; !slices.Equal(results[0].Regs, want) {
+		t.Errorf("got registers %d, want %d", results[0].Regs, want)
+	}
+	if want := []bool{false, true}; !slices.Equal(results[1].Bits, want) {
+		t.Errorf("got coils %t, want %t", results[1].Bits, want)
+	}
+	if len(results[2].Regs) != 12 || results[2].Regs[11] != 111 {
+		t.Errorf("got registers %d, want 100 up to 111", results[2].Regs)
+	}
+	// holding registers merged into one transaction
+	if got := client.Stats().TxN; got != 2 {
+		t.Errorf("got %d transactions, want 2", got)
+	}
+}
+
+// Overlapping blocks beyond the register limit can not merge into one read.
+func TestPollOverlap(t *testing.T) {
+	server, client := testServerClient(t)
+	for addr := uint16(0); addr < 150; addr++ {
+		server.SetHoldReg(addr, addr)
+	}
+
+	blocks := []modbus.ReadBlock{
+		{FuncCode: 0x03, Addr: 0, N: 100},
+		{FuncCode: 0x03, Addr: 50, N: 100},
+	}
+	var results []modbus.BlockResult
+	ctx, cancel := context.WithCancel(context.Background())
+	client.Poll(ctx, time.Hour, blocks, func(r modbus.BlockResult) {
+		results = append(results, r)
+		if len(results) == len(blocks) {
+			cancel()
+		}
+	})
+	checkOverlap(t, blocks, results)
+	if got := client.Stats().TxN; got != 2 {
+		t.Errorf("got %d transactions, want 2", got)
+	}
+}
+
+func TestPollInterval(t *testing.T) {
+	var client modbus.TCPClient // no connection needed
+	blocks := []modbus.ReadBlock{{FuncCode: 0x03, Addr: 100, N: 1}}
+	for _, interval := range []time.Duration{0, -time.Second} {
+		err := client.Poll(context.Background(), interval, blocks, func(modbus.BlockResult) {
+			t.Errorf("interval %s: callback invoked", interval)
+		})
+		if err == nil {
+			t.Errorf("interval %s: no error", interval)
+		}
+	}
+	if got := client.Stats().TxN; got != 0 {
+		t.Errorf("got %d transactions, want 0", got)
+	}
+}
+
+func TestReadBlocks(t *testing.T) {
+	server, client := testServerClient(t)
+	server.SetCoil(10, true)
+	server.SetCoil(13, true)
+	server.SetDiscreteInput(11, true)
+
+	blocks := []modbus.ReadBlock{
+		{FuncCode: 0x01, Addr: 10, N: 2},
+		{FuncCode: 0x02, Addr: 10, N: 2},
+		{FuncCode: 0x01, Addr: 12, N: 2},
+	}
+	results, err := client.ReadBlocks(blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]bool{{true, false}, {false, true}, {false, true}}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+	for i, r := range results {
+		if r.Err != nil {
+			t.Errorf("block %d error: %s", i, r.Err)
+		}
+		if !slices.Equal(r.Bits, want[i]) {
+			t.Errorf("block %d got %t, want %t", i, r.Bits, want[i])
+		}
+	}
+	// adjacent coils merged, and discrete inputs apart
+	if got := client.Stats().TxN; got != 2 {
+		t.Errorf("got %d transactions, want 2", got)
+	}
+
+	_, err = client.ReadBlocks([]modbus.ReadBlock{{FuncCode: 0x05, Addr: 1, N: 1}})
+	if err == nil {
+		t.Error("no error for write function code")
+	}
+}
+
+func TestReadBlocksOverlap(t *testing.T) {
+	server, client := testServerClient(t)
+	for addr := uint16(0); addr < 150; addr++ {
+		server.SetHoldReg(addr, addr)
+	}
+
+	blocks := []modbus.ReadBlock{
+		{FuncCode: 0x03, Addr: 50, N: 100},
+		{FuncCode: 0x03, Addr: 0, N: 100},
+		{FuncCode: 0x03, Addr: 60, N: 10},
+	}
+	results, err := client.ReadBlocks(blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkOverlap(t, blocks, results)
+	if got := client.Stats().TxN; got != 2 {
+		t.Errorf("got %d transactions, want 2", got)
+	}
+}
+
+// CheckOverlap verifies results from a server with each holding register set
+// to its own address.
+func checkOverlap(t *testing.T, blocks []modbus.ReadBlock, results []modbus.BlockResult) {
+	t.Helper()
+	if len(results) != len(blocks) {
+		t.Fatalf("got %d results, want %d", len(results), len(blocks))
+	}
+	for i, r := range results {
+		if r.Err != nil {
+			t.Errorf("block %d error: %s", i, r.Err)
+			continue
+		}
+		want := make([]uint16, blocks[i].N)
+		for j := range want {
+			want[j] = blocks[i].Addr + uint16(j)
+		}
+		if !slices.Equal(r.Regs, want) {
+			t.Errorf("block %d got registers %d, want %d", i, r.Regs, want)
+		}
+	}
+}
+
+func TestCoalesceReads(t *testing.T) {
+	addrs := []uint16{151, 100, 101, 150, 101, 400}
+	got := modbus.CoalesceReads(addrs, 10)
+	want := []modbus.ReadRange{{100, 2}, {150, 2}, {400, 1}}
+	if !slices.Equal(got, want) {
+		t.Errorf("max gap 10 got %v, want %v", got, want)
+	}
+
+	got = modbus.CoalesceReads(addrs, 49)
+	want = []modbus.ReadRange{{100, 52}, {400, 1}}
+	if !slices.Equal(got, want) {
+		t.Errorf("max gap 49 got %v, want %v", got, want)
+	}
+
+	got = modbus.CoalesceReads([]uint16{0, 124, 125}, 200)
+	want = []modbus.ReadRange{{0, 125}, {125, 1}}
+	if !slices.Equal(got, want) {
+		t.Errorf("range limit got %v, want %v", got, want)
+	}
+}
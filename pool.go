@@ -0,0 +1,118 @@
+package modbus
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Pool manages TCPClients for concurrent use. Each client goes to one goroutine
+// at a time, from Acquire until Release.
+type Pool struct {
+	// New returns a client, typically for the same RemoteAddr each time.
+	// Clients connect on demand, i.e., New does not need to connect.
+	New func() *TCPClient
+
+	// Limit the number of clients, both idle and in use. Acquire blocks
+	// while the limit is reached. The zero value omits the limit.
+	MaxOpen int
+
+	// Close idle clients after a period of inactivity. The zero value
+	// keeps idle clients indefinitely.
+	MaxIdleTime time.Duration
+
+	mutex sync.Mutex // guards the fields below
+	// semaphore for MaxOpen, initiated lazily
+	slots chan struct{}
+	// stack with the most recent release last
+	idle []idleClient
+}
+
+type idleClient struct {
+	*TCPClient
+	since time.Time
+}
+
+// Acquire returns a client for exclusive use until Release. The context
+// applies to the wait on MaxOpen only.
+func (p *Pool) Acquire(ctx context.Context) (*TCPClient, error) {
+	p.mutex.Lock()
+	if p.MaxOpen > 0 && p.slots == nil {
+		p.slots = make(chan struct{}, p.MaxOpen)
+	}
+	slots := p.slots
+	p.mutex.Unlock()
+
+	if slots != nil {
+		select {
+		case slots <- struct{}{}:
+			break // got slot
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	p.mutex.Lock()
+	p.closeExpired()
+	var c *TCPClient
+	if n := len(p.idle); n != 0 {
+		c = p.idle[n-1].TCPClient
+		p.idle[n-1] = idleClient{}
+		p.idle = p.idle[:n-1]
+	}
+	p.mutex.Unlock()
+
+	if c == nil {
+		c = p.New()
+	}
+	return c, nil
+}
+
+// Release returns a client from Acquire. Clients without a connection, such
+// as the ones reset on failure, are discarded.
+func (p *Pool) Release(c *TCPClient) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if c.Conn != nil {
+		p.idle = append(p.idle, idleClient{c, time.Now()})
+	}
+	p.closeExpired()
+
+	if p.slots != nil {
+		<-p.slots
+	}
+}
+
+// CloseExpired closes the clients beyond MaxIdleTime. The mutex must be held.
+func (p *Pool) closeExpired() {
+	if p.MaxIdleTime <= 0 {
+		return
+	}
+	expire := time.Now().Add(-p.MaxIdleTime)
+	var n int
+	for n < len(p.idle) && p.idle[n].since.Before(expire) {
+		p.idle[n].Close()
+		n++
+	}
+	if n != 0 {
+		p.idle = append(p.idle[:0], p.idle[n:]...)
+	}
+}
+
+// Close closes all idle clients. Clients in use remain unaffected.
+func (p *Pool) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var firstErr error
+	for i := range p.idle {
+		err := p.idle[i].Close()
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		p.idle[i] = idleClient{}
+	}
+	p.idle = p.idle[:0]
+	return firstErr
+}
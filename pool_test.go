@@ -0,0 +1,68 @@
+package modbus_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pascaldekloe/modbus"
+)
+
+func TestPool(t *testing.T) {
+	var newN int
+	p := modbus.Pool{
+		New: func() *modbus.TCPClient {
+			newN++
+			conn, _ := net.Pipe()
+			return &modbus.TCPClient{Conn: conn}
+		},
+		MaxOpen: 2,
+	}
+	defer p.Close()
+
+	ctx := context.Background()
+	c1, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c1 == c2 {
+		t.Fatal("same client acquired twice")
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := p.Acquire(timeoutCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v beyond MaxOpen, want context.DeadlineExceeded", err)
+	}
+
+	p.Release(c1)
+	c3, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c3 != c1 {
+		t.Error("idle client not reused")
+	}
+
+	// connection reset on failure
+	c3.Close()
+	p.Release(c3)
+	c4, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c4 == c3 {
+		t.Error("client without connection reused")
+	}
+	if newN != 3 {
+		t.Errorf("got %d clients created, want 3", newN)
+	}
+	p.Release(c2)
+	p.Release(c4)
+}
@@ -0,0 +1,40 @@
+package modbus
+
+import "errors"
+
+// DetectAddrBase resolves whether a register number from documentation counts
+// from 0 or from 1. The holding register is read at both ref and ref − 1, with
+// ref as the documented number without any table prefix, e.g., 40001 is 1.
+// Sane should report whether a value is plausible for the documented datum.
+// Base is 1 when only ref − 1 is sane, and base is 0 otherwise. Certain is set
+// when exactly one of the two interpretations is sane.
+func (c *TCPClient) DetectAddrBase(ref uint16, sane func(uint16) bool) (base int, certain bool, err error) {
+	zeroSane, err := c.saneHoldReg(ref, sane)
+	if err != nil {
+		return 0, false, err
+	}
+	if ref == 0 {
+		return 0, zeroSane, nil
+	}
+	oneSane, err := c.saneHoldReg(ref-1, sane)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if oneSane && !zeroSane {
+		return 1, true, nil
+	}
+	return 0, zeroSane && !oneSane, nil
+}
+
+// SaneHoldReg returns whether a holding register is readable and sane.
+func (c *TCPClient) saneHoldReg(addr uint16, sane func(uint16) bool) (bool, error) {
+	v, err := c.ReadHoldReg(addr)
+	if err != nil {
+		if errors.As(err, new(Exception)) {
+			return false, nil
+		}
+		return false, err
+	}
+	return sane(v), nil
+}
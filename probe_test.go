@@ -0,0 +1,50 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestDetectAddrBase(t *testing.T) {
+	// plausible voltage in volts
+	sane := func(v uint16) bool { return v >= 200 && v <= 250 }
+
+	tests := []struct {
+		name        string
+		ref         uint16
+		regs        map[uint16]uint16 // absent registers raise ErrAddr
+		wantBase    int
+		wantCertain bool
+	}{
+		{"0-based", 100, map[uint16]uint16{99: 0, 100: 230}, 0, true},
+		{"1-based", 100, map[uint16]uint16{99: 230, 100: 0}, 1, true},
+		{"both sane", 100, map[uint16]uint16{99: 230, 100: 231}, 0, false},
+		{"none sane", 100, map[uint16]uint16{99: 0, 100: 0}, 0, false},
+		{"1-based with exception", 100, map[uint16]uint16{99: 230}, 1, true},
+		{"0-based with exception", 100, map[uint16]uint16{100: 230}, 0, true},
+		{"reference 0", 0, map[uint16]uint16{0: 230}, 0, true},
+	}
+	for _, test := range tests {
+		c := TCPClient{
+			Conn: &fakeDevice{respond: func(req []byte) []byte {
+				v, ok := test.regs[binary.BigEndian.Uint16(req[8:10])]
+				if !ok {
+					frame := responseFrame(req, byte(ErrAddr))
+					frame[7] |= errorFlag
+					return frame
+				}
+				return responseFrame(req, 2, byte(v>>8), byte(v))
+			}},
+			UnitID: 1,
+		}
+
+		base, certain, err := c.DetectAddrBase(test.ref, sane)
+		if err != nil {
+			t.Errorf("%s: got error: %s", test.name, err)
+			continue
+		}
+		if base != test.wantBase || certain != test.wantCertain {
+			t.Errorf("%s: got base %d with certain %t, want %d with %t", test.name, base, certain, test.wantBase, test.wantCertain)
+		}
+	}
+}
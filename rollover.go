@@ -0,0 +1,46 @@
+package modbus
+
+import "time"
+
+// Rollover accumulates a counter which wraps around, such as uptime in seconds.
+// Any value below its predecessor counts as a single rollover. Note that a
+// device restart looks like a rollover too.
+type Rollover struct {
+	Bits int           // counter width, with zero for 16 (one register)
+	Unit time.Duration // counter resolution, with zero for time.Second
+
+	prev  uint64
+	total time.Duration
+	seen  bool
+}
+
+// Update registers a counter value, and it returns the Total.
+func (r *Rollover) Update(count uint64) time.Duration {
+	unit := r.Unit
+	if unit == 0 {
+		unit = time.Second
+	}
+
+	if !r.seen {
+		r.seen = true
+		r.total = time.Duration(count) * unit
+	} else {
+		bits := r.Bits
+		if bits == 0 {
+			bits = 16
+		}
+		delta := count - r.prev
+		if bits < 64 {
+			delta &= 1<<bits - 1
+		}
+		r.total += time.Duration(delta) * unit
+	}
+	r.prev = count
+	return r.total
+}
+
+// Total returns the counter value from the first Update plus any progress ever
+// since, including the rollovers.
+func (r *Rollover) Total() time.Duration {
+	return r.total
+}
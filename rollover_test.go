@@ -0,0 +1,30 @@
+package modbus_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pascaldekloe/modbus"
+)
+
+func TestRollover(t *testing.T) {
+	r := modbus.Rollover{Bits: 16}
+	for _, count := range []uint64{65000, 65535, 100, 64000, 5} {
+		r.Update(count)
+	}
+	want := (65000 + 535 + 101 + 63900 + 1541) * time.Second
+	if got := r.Total(); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestRolloverZeroValue(t *testing.T) {
+	var r modbus.Rollover // 16 bits in seconds
+	for _, count := range []uint64{65000, 65535, 100} {
+		r.Update(count)
+	}
+	want := (65000 + 535 + 101) * time.Second
+	if got := r.Total(); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
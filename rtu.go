@@ -0,0 +1,274 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// RTUClient manages a serial line for use from within a single goroutine.
+// Transactions are dealt with sequentially—only one request at a time.
+type RTUClient struct {
+	// Buf is (re)used for both reading and writing. Frames consist of the
+	// unit identifier, the PDU, and a 2-byte CRC.
+	buf [1 + MaxPDUSize + 2]byte
+
+	// Serial port in use.
+	io.ReadWriteCloser
+
+	// Baud rate determines the 3.5-character silence between frames, which
+	// applies before each request, and to discard input after frame errors.
+	// The zero value omits silence protection.
+	Baud int
+
+	// Limit the time for a response, when the port supports read deadlines
+	// with a SetReadDeadline method. The zero value omits timeouts.
+	TxTimeout time.Duration
+
+	// read-only transaction counter
+	TxN uint64
+
+	// The unit identifier of the device, in range [1, 247]. Broadcasts to
+	// address 0x00 are sent without awaiting a response.
+	UnitID byte
+
+	// end of the last frame on the line
+	lastIO time.Time
+}
+
+// FrameGap returns the minimum silence between frames. The specification sets
+// a fixed 1.75 ms for rates over 19200 baud.
+func (c *RTUClient) frameGap() time.Duration {
+	switch {
+	case c.Baud <= 0:
+		return 0
+	case c.Baud > 19200:
+		return 1750 * time.Microsecond
+	}
+	// 3.5 characters of 11 bits each
+	return time.Duration(38.5 * float64(time.Second) / float64(c.Baud))
+}
+
+// CRC16 returns the Modbus checksum of p.
+func crc16(p []byte) uint16 {
+	crc := uint16(0xffff)
+	for _, b := range p {
+		crc ^= uint16(b)
+		for range 8 {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xa001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
+
+// Exchange implements the Transport interface. Response bytes stop being valid
+// at the next invocation to the RTUClient. Input is discarded after a frame
+// error, up to a 3.5-character silence, such that the next frame starts clean.
+func (c *RTUClient) Exchange(funcCode byte, data []byte) (res []byte, err error) {
+	if 1+len(data) > MaxPDUSize {
+		return nil, ErrLimit
+	}
+	c.buf[0] = c.UnitID
+	c.buf[1] = funcCode
+	n := 2 + copy(c.buf[2:], data)
+	binary.LittleEndian.PutUint16(c.buf[n:n+2], crc16(c.buf[:n]))
+	n += 2
+
+	if gap := c.frameGap(); gap != 0 {
+		time.Sleep(time.Until(c.lastIO.Add(gap)))
+	}
+
+	c.TxN++
+
+	_, err = c.Write(c.buf[:n])
+	c.lastIO = time.Now()
+	if err != nil {
+		return nil, fmt.Errorf("Modbus request submission: %w", err)
+	}
+	if c.UnitID == 0 {
+		return nil, nil // broadcast
+	}
+
+	defer func() {
+		if err != nil && !errors.As(err, new(Exception)) {
+			c.drain()
+		}
+	}()
+
+	if d, ok := c.ReadWriteCloser.(interface{ SetReadDeadline(time.Time) error }); ok && c.TxTimeout != 0 {
+		err := d.SetReadDeadline(time.Now().Add(c.TxTimeout))
+		if err != nil {
+			return nil, fmt.Errorf("timeout on Modbus connection needed: %w", err)
+		}
+		defer d.SetReadDeadline(time.Time{})
+	}
+
+	readN, err := c.readFrame()
+	c.lastIO = time.Now()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("Modbus response unavailable: %w", err)
+	}
+
+	if binary.LittleEndian.Uint16(c.buf[readN-2:readN]) != crc16(c.buf[:readN-2]) {
+		return nil, errors.New("Modbus RTU response CRC mismatch")
+	}
+	if c.buf[0] != c.UnitID {
+		return nil, fmt.Errorf("Modbus RTU response from unit %#02x does not match request to unit %#02x",
+			c.buf[0], c.UnitID)
+	}
+	switch c.buf[1] {
+	case funcCode:
+		break // regular response
+	case funcCode | errorFlag:
+		return nil, Exception(c.buf[2])
+	default:
+		return nil, fmt.Errorf("Modbus RTU response function code %#02x does not match request function code %#02x",
+			c.buf[1], funcCode)
+	}
+	return c.buf[2 : readN-2], nil
+}
+
+// Drain discards input until a 3.5-character silence. Ports without read
+// deadlines are left as is, and so are ports without a Baud rate.
+func (c *RTUClient) drain() {
+	gap := c.frameGap()
+	d, ok := c.ReadWriteCloser.(interface{ SetReadDeadline(time.Time) error })
+	if gap == 0 || !ok {
+		return
+	}
+	defer d.SetReadDeadline(time.Time{})
+
+	// limit to one frame in size against noise
+	for n := 0; n < len(c.buf); {
+		if d.SetReadDeadline(time.Now().Add(gap)) != nil {
+			return
+		}
+		readN, err := c.Read(c.buf[:])
+		if err != nil {
+			return // silence, or unavailable
+		}
+		n += readN
+		c.lastIO = time.Now()
+	}
+}
+
+// ReadFrame reads a response into c.buf. RTU frames lack a length field. The
+// size is inferred from the function code instead.
+func (c *RTUClient) readFrame() (readN int, err error) {
+	readTo := func(n int) error {
+		if n > len(c.buf) {
+			return errors.New("Modbus frame size exceeds reponse [PDU] limit")
+		}
+		if n > readN {
+			_, err := io.ReadFull(c.ReadWriteCloser, c.buf[readN:n])
+			if err != nil {
+				return err
+			}
+			readN = n
+		}
+		return nil
+	}
+
+	// unit identifier, function code, and one more
+	if err := readTo(3); err != nil {
+		return readN, err
+	}
+
+	var end int
+	switch funcCode := c.buf[1]; {
+	case funcCode&errorFlag != 0:
+		end = 3
+	case funcCode == readCoils, funcCode == readDiscreteInputs,
+		funcCode == readHoldRegs, funcCode == readInputRegs,
+		funcCode == reportServerID, funcCode == readFile,
+		funcCode == writeFile, funcCode == readWriteRegs:
+		end = 3 + int(c.buf[2])
+	case funcCode == writeCoil, funcCode == writeReg, funcCode == diagnostics,
+		funcCode == writeCoils, funcCode == writeRegs:
+		end = 6
+	case funcCode == maskWriteReg:
+		end = 8
+	case funcCode == readFIFO:
+		if err := readTo(4); err != nil {
+			return readN, err
+		}
+		end = 4 + int(binary.BigEndian.Uint16(c.buf[2:4]))
+	case funcCode == encapsulated:
+		// MEI type, code, conformity, more follows, next, object count
+		if err := readTo(8); err != nil {
+			return readN, err
+		}
+		end = 8
+		for range int(c.buf[7]) {
+			if err := readTo(end + 2); err != nil {
+				return readN, err
+			}
+			end += 2 + int(c.buf[end+1])
+		}
+	default:
+		return readN, fmt.Errorf("Modbus RTU response size unknown for function code %#02x", funcCode)
+	}
+
+	// CRC
+	return readN, readTo(end + 2)
+}
+
+// ReadInputReg fetches an input register at the given address.
+func (c *RTUClient) ReadInputReg(addr uint16) (uint16, error) {
+	return Client{c}.ReadInputReg(addr)
+}
+
+// ReadHoldReg fetches a holding register at the given address.
+func (c *RTUClient) ReadHoldReg(addr uint16) (uint16, error) {
+	return Client{c}.ReadHoldReg(addr)
+}
+
+// ReadInputRegs fetches consecutive input-registers at a start address into a
+// read buffer. The return is ErrLimit when buf is larger than 125 entries.
+func (c *RTUClient) ReadInputRegs(buf []uint16, startAddr uint16) error {
+	return Client{c}.ReadInputRegs(buf, startAddr)
+}
+
+// ReadHoldRegs fetches consecutive holding-registers at a start address into a
+// read buffer. The return is ErrLimit when buf is larger than 125 entries.
+func (c *RTUClient) ReadHoldRegs(buf []uint16, startAddr uint16) error {
+	return Client{c}.ReadHoldRegs(buf, startAddr)
+}
+
+// ReadCoils fetches consecutive coils at a start address into a read buffer.
+// The return is ErrLimit when buf is larger than 2000 entries.
+func (c *RTUClient) ReadCoils(buf []bool, startAddr uint16) error {
+	return Client{c}.ReadCoils(buf, startAddr)
+}
+
+// ReadDiscreteInputs fetches consecutive discrete inputs at a start address
+// into a read buffer. The return is ErrLimit when buf is larger than 2000
+// entries.
+func (c *RTUClient) ReadDiscreteInputs(buf []bool, startAddr uint16) error {
+	return Client{c}.ReadDiscreteInputs(buf, startAddr)
+}
+
+// WriteReg updates a single register.
+func (c *RTUClient) WriteReg(addr, value uint16) error {
+	return Client{c}.WriteReg(addr, value)
+}
+
+// WriteCoil switches a single coil.
+func (c *RTUClient) WriteCoil(addr uint16, on bool) error {
+	return Client{c}.WriteCoil(addr, on)
+}
+
+// WriteRegs updates consecutive registers at a start address.
+// The return is ErrLimit when more than 123 values are given.
+func (c *RTUClient) WriteRegs(startAddr uint16, values ...uint16) error {
+	return Client{c}.WriteRegs(startAddr, values...)
+}
@@ -0,0 +1,117 @@
+package modbus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// RTUFrame returns the unit identifier and function code from req, followed by
+// the PDU data given and a CRC.
+func rtuFrame(req []byte, data ...byte) []byte {
+	frame := append(append([]byte(nil), req[:2]...), data...)
+	return binary.LittleEndian.AppendUint16(frame, crc16(frame))
+}
+
+func TestCRC16(t *testing.T) {
+	frame := []byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x0a}
+	if got, want := crc16(frame), uint16(0xcdc5); got != want {
+		t.Errorf("got CRC %#04x, want %#04x", got, want)
+	}
+}
+
+func TestRTUClient(t *testing.T) {
+	var lastReq []byte
+	dev := &fakeDevice{respond: func(req []byte) []byte {
+		lastReq = req
+		switch req[1] {
+		case readHoldRegs:
+			return rtuFrame(req, 4, 0x12, 0x34, 0x56, 0x78)
+		case writeReg:
+			return rtuFrame(req, req[2:6]...)
+		case readCoils:
+			return rtuFrame(req, 1, 0x05)
+		}
+		return []byte{req[0], req[1] | errorFlag, byte(ErrFunc), 0, 0}
+	}, chunk: 3}
+	c := RTUClient{ReadWriteCloser: dev, UnitID: 7}
+
+	var regs [2]uint16
+	if err := c.ReadHoldRegs(regs[:], 100); err != nil {
+		t.Fatal("read holding registers error:", err)
+	}
+	if regs != [2]uint16{0x1234, 0x5678} {
+		t.Errorf("got registers %#04x, want [0x1234 0x5678]", regs)
+	}
+	wantReq := []byte{7, readHoldRegs, 0, 100, 0, 2}
+	wantReq = binary.LittleEndian.AppendUint16(wantReq, crc16(wantReq))
+	if !bytes.Equal(lastReq, wantReq) {
+		t.Errorf("got request %#x, want %#x", lastReq, wantReq)
+	}
+
+	if err := c.WriteReg(5, 0xbeef); err != nil {
+		t.Error("write register error:", err)
+	}
+
+	var coils [3]bool
+	if err := c.ReadCoils(coils[:], 0); err != nil {
+		t.Error("read coils error:", err)
+	} else if coils != [3]bool{true, false, true} {
+		t.Errorf("got coils %t, want [true false true]", coils)
+	}
+
+	// exception with a zero CRC
+	err := c.WriteCoil(1, true)
+	if err == nil || errors.Is(err, ErrFunc) {
+		t.Errorf("got error %v for corrupt frame, want CRC mismatch", err)
+	}
+
+	if c.TxN != 4 {
+		t.Errorf("got TxN %d, want 4", c.TxN)
+	}
+}
+
+func TestRTUDrain(t *testing.T) {
+	tests := []struct {
+		name    string
+		respond func(req []byte) []byte
+	}{
+		{"CRC", func(req []byte) []byte {
+			frame := rtuFrame(req, 2, 0, 42)
+			frame[len(frame)-1]++
+			return append(frame, 1, 2, 3)
+		}},
+		{"unit identifier", func(req []byte) []byte {
+			frame := rtuFrame([]byte{req[0] + 1, req[1]}, 2, 0, 42)
+			return append(frame, 1, 2, 3)
+		}},
+		{"function code", func(req []byte) []byte {
+			frame := rtuFrame([]byte{req[0], readInputRegs}, 2, 0, 42)
+			return append(frame, 1, 2, 3)
+		}},
+	}
+	for _, test := range tests {
+		dev := &fakeDevice{respond: test.respond, chunk: 4}
+		c := RTUClient{ReadWriteCloser: dev, Baud: 115200, UnitID: 7}
+		if _, err := c.ReadHoldReg(1); err == nil {
+			t.Fatalf("%s: no error for corrupt frame", test.name)
+		}
+		if len(dev.pending) != 0 {
+			t.Errorf("%s: got %#x pending after error, want input drained", test.name, dev.pending)
+		}
+	}
+
+	// exceptions are valid frames
+	dev := &fakeDevice{respond: func(req []byte) []byte {
+		frame := rtuFrame([]byte{req[0], req[1] | errorFlag}, byte(ErrAddr))
+		return append(frame, 1, 2, 3)
+	}}
+	c := RTUClient{ReadWriteCloser: dev, Baud: 115200, UnitID: 7}
+	if _, err := c.ReadHoldReg(1); !errors.Is(err, ErrAddr) {
+		t.Fatalf("got error %v, want ErrAddr", err)
+	}
+	if len(dev.pending) != 3 {
+		t.Errorf("got %#x pending after exception, want the 3 trailing bytes", dev.pending)
+	}
+}
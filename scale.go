@@ -0,0 +1,121 @@
+package modbus
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrValueRange denies an encoding for lack of register capacity.
+var ErrValueRange = errors.New("Modbus register value out of range")
+
+// LinearScale converts between raw register values and engineering units with
+// raw × Scale + Offset. Both Scale and Offset may be negative. The zero Scale
+// has no Inverse.
+type LinearScale struct {
+	Scale, Offset float64
+}
+
+// Apply returns the engineering value of raw.
+func (s LinearScale) Apply(raw float64) float64 {
+	return raw*s.Scale + s.Offset
+}
+
+// Inverse returns the raw value of eng.
+func (s LinearScale) Inverse(eng float64) float64 {
+	return (eng - s.Offset) / s.Scale
+}
+
+// Reg returns the engineering value of an unsigned register.
+func (s LinearScale) Reg(r uint16) float64 {
+	return s.Apply(float64(r))
+}
+
+// RegInt16 returns the engineering value of a signed register.
+func (s LinearScale) RegInt16(r uint16) float64 {
+	return s.Apply(float64(int16(r)))
+}
+
+// PutReg returns the unsigned register value of eng. The Inverse is rounded to
+// the nearest integer, with halfway values away from zero. The return is
+// ErrValueRange when the outcome does not fit in 16 bits.
+func (s LinearScale) PutReg(eng float64) (uint16, error) {
+	raw := math.Round(s.Inverse(eng))
+	if !(raw >= 0 && raw <= math.MaxUint16) {
+		return 0, ErrValueRange
+	}
+	return uint16(raw), nil
+}
+
+// PutRegInt16 returns the signed register value of eng. The Inverse is rounded
+// to the nearest integer, with halfway values away from zero. The return is
+// ErrValueRange when the outcome does not fit in 16 bits.
+func (s LinearScale) PutRegInt16(eng float64) (uint16, error) {
+	raw := math.Round(s.Inverse(eng))
+	if !(raw >= math.MinInt16 && raw <= math.MaxInt16) {
+		return 0, ErrValueRange
+	}
+	return uint16(int16(raw)), nil
+}
+
+// ScaleMode is the encoding of a scale register, which applies to a value in
+// another register.
+type ScaleMode uint8
+
+// Scale Modes
+const (
+	// ScalePow10 is a signed power-of-ten exponent, e.g., -2 for 0.01.
+	ScalePow10 ScaleMode = iota
+	// ScaleFactor is an unsigned multiplier.
+	ScaleFactor
+
+	// ScaleSigned is a flag for value registers in two's complement. The
+	// default is unsigned.
+	ScaleSigned ScaleMode = 0x80
+)
+
+// ReadScaledByRegister fetches a holding register with its scale as reported by
+// the device in another holding register. Both registers are read in a single
+// transaction when adjacent, without wraparound.
+func (c *TCPClient) ReadScaledByRegister(valueAddr, scaleAddr uint16, mode ScaleMode) (float64, error) {
+	if m := mode &^ ScaleSigned; m != ScalePow10 && m != ScaleFactor {
+		return 0, fmt.Errorf("Modbus scale mode %#02x unknown", uint8(mode))
+	}
+
+	var raw, scale uint16
+	switch {
+	case int(scaleAddr) == int(valueAddr)+1:
+		var regs [2]uint16
+		err := c.ReadHoldRegs(regs[:], valueAddr)
+		if err != nil {
+			return 0, err
+		}
+		raw, scale = regs[0], regs[1]
+	case int(valueAddr) == int(scaleAddr)+1:
+		var regs [2]uint16
+		err := c.ReadHoldRegs(regs[:], scaleAddr)
+		if err != nil {
+			return 0, err
+		}
+		scale, raw = regs[0], regs[1]
+	default:
+		var err error
+		raw, err = c.ReadHoldReg(valueAddr)
+		if err != nil {
+			return 0, err
+		}
+		scale, err = c.ReadHoldReg(scaleAddr)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	v := float64(raw)
+	if mode&ScaleSigned != 0 {
+		v = float64(int16(raw))
+	}
+	if mode&^ScaleSigned == ScaleFactor {
+		return v * float64(scale), nil
+	}
+	return v * math.Pow10(int(int16(scale))), nil
+}
@@ -0,0 +1,67 @@
+package modbus_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pascaldekloe/modbus"
+)
+
+func TestLinearScale(t *testing.T) {
+	s := modbus.LinearScale{Scale: -0.5, Offset: 100}
+	if got := s.RegInt16(0xfffe); got != 101 {
+		t.Errorf("got %f for raw -2, want 101", got)
+	}
+
+	r, err := s.PutRegInt16(99.25)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// raw 1.5 rounds away from zero
+	if r != 2 {
+		t.Errorf("got raw %d for 99.25, want 2", r)
+	}
+
+	_, err = s.PutReg(101)
+	if !errors.Is(err, modbus.ErrValueRange) {
+		t.Errorf("got error %v for negative raw, want ErrValueRange", err)
+	}
+}
+
+func TestReadScaledByRegister(t *testing.T) {
+	server, client := testServerClient(t)
+
+	tests := []struct {
+		name      string
+		valueAddr uint16
+		scaleAddr uint16
+		value     uint16
+		scale     uint16
+		mode      modbus.ScaleMode
+		want      float64
+		wantTxN   uint64
+	}{
+		{"adjacent", 10, 11, 1234, 0xfffe, modbus.ScalePow10, 12.34, 1},
+		{"reversed", 21, 20, 0xfff6, 3, modbus.ScaleFactor | modbus.ScaleSigned, -30, 1},
+		{"apart", 30, 40, 5, 2, modbus.ScalePow10, 500, 2},
+		{"wraparound", 0xffff, 0, 7, 1, modbus.ScaleFactor, 7, 2},
+		{"wraparound reversed", 0, 0xffff, 8, 2, modbus.ScaleFactor, 16, 2},
+	}
+	for _, test := range tests {
+		server.SetHoldReg(test.valueAddr, test.value)
+		server.SetHoldReg(test.scaleAddr, test.scale)
+
+		txN := client.Stats().TxN
+		got, err := client.ReadScaledByRegister(test.valueAddr, test.scaleAddr, test.mode)
+		if err != nil {
+			t.Errorf("%s: got error: %s", test.name, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: got %g, want %g", test.name, got, test.want)
+		}
+		if n := client.Stats().TxN - txN; n != test.wantTxN {
+			t.Errorf("%s: got %d transactions, want %d", test.name, n, test.wantTxN)
+		}
+	}
+}
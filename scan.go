@@ -0,0 +1,40 @@
+package modbus
+
+import (
+	"context"
+	"iter"
+)
+
+// ScanHoldRegs returns an iterator of the holding registers from startAddr up
+// to and including endAddr, as address–value pairs. Reads go in batches of 125
+// registers, bound to ctx conform WithContext. Iteration stops on the first
+// error, which is available from the function in return once the loop ends.
+//
+//	regs, errFunc := client.ScanHoldRegs(ctx, 0, 999)
+//	for addr, value := range regs {
+//		fmt.Printf("%d: %#04x\n", addr, value)
+//	}
+//	if err := errFunc(); err != nil {
+//		log.Print(err)
+//	}
+func (c *TCPClient) ScanHoldRegs(ctx context.Context, startAddr, endAddr uint16) (iter.Seq2[uint16, uint16], func() error) {
+	var err error
+	seq := func(yield func(addr, value uint16) bool) {
+		err = nil
+		var buf [125]uint16
+		for addr := int(startAddr); addr <= int(endAddr); {
+			chunk := buf[:min(len(buf), int(endAddr)-addr+1)]
+			err = c.ReadHoldRegsContext(ctx, chunk, uint16(addr))
+			if err != nil {
+				return
+			}
+			for i, value := range chunk {
+				if !yield(uint16(addr+i), value) {
+					return
+				}
+			}
+			addr += len(chunk)
+		}
+	}
+	return seq, func() error { return err }
+}
@@ -0,0 +1,60 @@
+package modbus_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/pascaldekloe/modbus"
+)
+
+func TestScanHoldRegs(t *testing.T) {
+	server, client := testServerClient(t)
+	for addr := uint16(0xff00); addr != 0; addr++ {
+		server.SetHoldReg(addr, ^addr)
+	}
+
+	regs, errFunc := client.ScanHoldRegs(context.Background(), 0xff00, 0xffff)
+	want := uint16(0xff00)
+	var n int
+	for addr, value := range regs {
+		if addr != want || value != ^addr {
+			t.Fatalf("got register %#04x with value %#04x, want register %#04x", addr, value, want)
+		}
+		want++
+		n++
+	}
+	if err := errFunc(); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0x100 {
+		t.Errorf("got %d registers, want 256", n)
+	}
+	// 3 batches of 125, 125 and 6 registers
+	if got := client.Stats().TxN; got != 3 {
+		t.Errorf("got %d transactions, want 3", got)
+	}
+
+	// exception ends the scan
+	server.SetException(0x03, 0xff80, modbus.ErrDev)
+	n = 0
+	for range regs {
+		n++
+	}
+	if err := errFunc(); !errors.Is(err, modbus.ErrDev) {
+		t.Errorf("got error %v, want ErrDev", err)
+	}
+	if n != 125 {
+		t.Errorf("got %d registers before the exception, want the first batch of 125", n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	regs, errFunc = client.ScanHoldRegs(ctx, 0, 9)
+	for range regs {
+		t.Error("got register from canceled scan")
+	}
+	if err := errFunc(); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,42 @@
+package modbus
+
+import "sync"
+
+// Result is the outcome of a function applied to a client.
+type Result struct {
+	Client *TCPClient
+	Value  any
+	Err    error
+}
+
+// ScatterRead applies fn to each client concurrently, with at most workers
+// goroutines at a time. Zero workers gets a goroutine per client. Each client
+// is used from within a single goroutine, conform the TCPClient contract.
+// Duplicate entries in clients are not permitted. The results are in the same
+// order as clients.
+func ScatterRead(clients []*TCPClient, workers int, fn func(*TCPClient) (any, error)) []Result {
+	results := make([]Result, len(clients))
+	if workers <= 0 || workers > len(clients) {
+		workers = len(clients)
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for range workers {
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				v, err := fn(clients[i])
+				results[i] = Result{Client: clients[i], Value: v, Err: err}
+			}
+		}()
+	}
+	for i := range clients {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	return results
+}
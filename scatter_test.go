@@ -0,0 +1,71 @@
+package modbus
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScatterRead(t *testing.T) {
+	clients := make([]*TCPClient, 4)
+	for i := range clients {
+		clients[i] = &TCPClient{
+			Conn: &fakeDevice{respond: func(req []byte) []byte {
+				if i == 2 {
+					frame := responseFrame(req, byte(ErrBusy))
+					frame[7] |= errorFlag
+					return frame
+				}
+				return responseFrame(req, 2, 0, byte(100+i))
+			}},
+			UnitID: 1,
+		}
+	}
+
+	tests := []struct {
+		workers   int
+		maxActive int32
+	}{
+		{0, 4},
+		{1, 1},
+		{2, 2},
+		{9, 4},
+	}
+	for _, test := range tests {
+		var active, maxActive atomic.Int32
+		results := ScatterRead(clients, test.workers, func(c *TCPClient) (any, error) {
+			n := active.Add(1)
+			defer active.Add(-1)
+			for {
+				max := maxActive.Load()
+				if n <= max || maxActive.CompareAndSwap(max, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			return c.ReadHoldReg(5)
+		})
+
+		if got := maxActive.Load(); got > test.maxActive {
+			t.Errorf("%d workers: got %d concurrent reads, want %d at most", test.workers, got, test.maxActive)
+		}
+		if len(results) != len(clients) {
+			t.Fatalf("%d workers: got %d results, want %d", test.workers, len(results), len(clients))
+		}
+		for i, r := range results {
+			if r.Client != clients[i] {
+				t.Errorf("%d workers: result %d has wrong client", test.workers, i)
+			}
+			if i == 2 {
+				if !errors.Is(r.Err, ErrBusy) {
+					t.Errorf("%d workers: result %d got error %v, want ErrBusy", test.workers, i, r.Err)
+				}
+				continue
+			}
+			if r.Err != nil || r.Value != uint16(100+i) {
+				t.Errorf("%d workers: result %d got (%v, %v), want (%d, nil)", test.workers, i, r.Value, r.Err, 100+i)
+			}
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+)
+
+// Server is a Modbus TCP device with its data model in memory. The intended
+// use is testing. All methods are safe for concurrent use.
+type Server struct {
+	ln net.Listener
+	wg sync.WaitGroup // connection routines
+
+	mutex sync.Mutex // guards the fields below
+	// data model with all addresses
+	holdRegs, inputRegs   [0x10000]uint16
+	coils, discreteInputs [0x10000]bool
+	// active connections
+	conns map[net.Conn]struct{}
+	// injected faults
+	exceptions map[serverAddr]Exception
+}
+
+// ServerAddr is an address per function code.
+type serverAddr struct {
+	funcCode byte
+	addr     uint16
+}
+
+// ListenServer starts a Server on a TCP address, such as "localhost:0" for a
+// random port. Use Close to stop.
+func ListenServer(addr string) (*Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{ln: ln, conns: make(map[net.Conn]struct{})}
+	s.wg.Add(1)
+	go s.acceptLoop()
+	return s, nil
+}
+
+// Addr returns the network address of the listener.
+func (s *Server) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// Close stops the listener, and it terminates all connections.
+func (s *Server) Close() error {
+	err := s.ln.Close()
+	s.mutex.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mutex.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+// HoldReg returns the holding register at the given address.
+func (s *Server) HoldReg(addr uint16) uint16 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.holdRegs[addr]
+}
+
+// SetHoldReg updates the holding register at the given address.
+func (s *Server) SetHoldReg(addr, value uint16) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.holdRegs[addr] = value
+}
+
+// InputReg returns the input register at the given address.
+func (s *Server) InputReg(addr uint16) uint16 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.inputRegs[addr]
+}
+
+// SetInputReg updates the input register at the given address.
+func (s *Server) SetInputReg(addr, value uint16) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.inputRegs[addr] = value
+}
+
+// Coil returns the coil at the given address.
+func (s *Server) Coil(addr uint16) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.coils[addr]
+}
+
+// SetCoil updates the coil at the given address.
+func (s *Server) SetCoil(addr uint16, on bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.coils[addr] = on
+}
+
+// DiscreteInput returns the discrete input at the given address.
+func (s *Server) DiscreteInput(addr uint16) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.discreteInputs[addr]
+}
+
+// SetDiscreteInput updates the discrete input at the given address.
+func (s *Server) SetDiscreteInput(addr uint16, on bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.discreteInputs[addr] = on
+}
+
+// SetException makes the Server respond with an exception to any request with
+// the function code which includes the address. The zero Exception removes
+// the setting.
+func (s *Server) SetException(funcCode byte, addr uint16, exc Exception) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	key := serverAddr{funcCode, addr}
+	if exc == 0 {
+		delete(s.exceptions, key)
+		return
+	}
+	if s.exceptions == nil {
+		s.exceptions = make(map[serverAddr]Exception)
+	}
+	s.exceptions[key] = exc
+}
+
+// Injected returns the exception from SetException, if any. The mutex must be
+// held.
+func (s *Server) injected(funcCode byte, data []byte) Exception {
+	if len(s.exceptions) == 0 || len(data) < 2 {
+		return 0
+	}
+	ranges := [][2]uint16{{binary.BigEndian.Uint16(data[0:2]), 1}}
+	switch funcCode {
+	case readCoils, readDiscreteInputs, readHoldRegs, readInputRegs, writeCoils, writeRegs:
+		if len(data) >= 4 {
+			ranges[0][1] = binary.BigEndian.Uint16(data[2:4])
+		}
+	case readWriteRegs:
+		if len(data) >= 8 {
+			ranges[0][1] = binary.BigEndian.Uint16(data[2:4])
+			ranges = append(ranges, [2]uint16{binary.BigEndian.Uint16(data[4:6]), binary.BigEndian.Uint16(data[6:8])})
+		}
+	}
+
+	for key, exc := range s.exceptions {
+		if key.funcCode != funcCode {
+			continue
+		}
+		for _, r := range ranges {
+			if key.addr >= r[0] && int(key.addr) < int(r[0])+int(r[1]) {
+				return exc
+			}
+		}
+	}
+	return 0
+}
+
+func (s *Server) acceptLoop() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return // closed
+		}
+
+		s.mutex.Lock()
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mutex.Unlock()
+		go s.serve(conn)
+	}
+}
+
+// Serve handles requests until the connection fails. Malformed frames are
+// fatal to the connection.
+func (s *Server) serve(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mutex.Lock()
+		delete(s.conns, conn)
+		s.mutex.Unlock()
+		conn.Close()
+	}()
+
+	var buf [MaxADUSize]byte
+	for {
+		_, err := io.ReadFull(conn, buf[:7])
+		if err != nil {
+			return
+		}
+		size := int(binary.BigEndian.Uint16(buf[4:6]))
+		if binary.BigEndian.Uint16(buf[2:4]) != 0 || size < 2 || size > 254 {
+			return
+		}
+		_, err = io.ReadFull(conn, buf[7:6+size])
+		if err != nil {
+			return
+		}
+
+		pdu := s.handle(buf[7:6+size], buf[7:7])
+		binary.BigEndian.PutUint16(buf[4:6], uint16(1+len(pdu)))
+		_, err = conn.Write(buf[:7+len(pdu)])
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Handle returns the response PDU for a request PDU. The response is appended
+// to buf. The request may be overwritten in the process.
+func (s *Server) handle(req, buf []byte) []byte {
+	funcCode := req[0]
+	data := req[1:]
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if e := s.injected(funcCode, data); e != 0 {
+		return append(buf[:0], funcCode|errorFlag, byte(e))
+	}
+	res, err := s.apply(funcCode, data, buf)
+	var e Exception
+	if errors.As(err, &e) {
+		return append(buf[:0], funcCode|errorFlag, byte(e))
+	}
+	return res
+}
+
+func (s *Server) apply(funcCode byte, data, buf []byte) ([]byte, error) {
+	// address and count/value are common to most function codes
+	var addr, n uint16
+	if len(data) >= 4 {
+		addr = binary.BigEndian.Uint16(data[0:2])
+		n = binary.BigEndian.Uint16(data[2:4])
+	}
+
+	switch funcCode {
+	case readCoils, readDiscreteInputs:
+		if len(data) != 4 || n == 0 || n > 2000 {
+			return nil, ErrValue
+		}
+		if int(addr)+int(n) > 0x10000 {
+			return nil, ErrAddr
+		}
+		bits := s.coils[addr : int(addr)+int(n)]
+		if funcCode == readDiscreteInputs {
+			bits = s.discreteInputs[addr : int(addr)+int(n)]
+		}
+		byteN := (len(bits) + 7) / 8
+		res := append(buf[:0], funcCode, byte(byteN))
+		res = append(res, make([]byte, byteN)...)
+		for i, on := range bits {
+			if on {
+				res[2+i/8] |= 1 << (i % 8)
+			}
+		}
+		return res, nil
+
+	case readHoldRegs, readInputRegs:
+		if len(data) != 4 || n == 0 || n > 125 {
+			return nil, ErrValue
+		}
+		if int(addr)+int(n) > 0x10000 {
+			return nil, ErrAddr
+		}
+		regs := s.holdRegs[addr : int(addr)+int(n)]
+		if funcCode == readInputRegs {
+			regs = s.inputRegs[addr : int(addr)+int(n)]
+		}
+		res := append(buf[:0], funcCode, byte(2*len(regs)))
+		for _, r := range regs {
+			res = binary.BigEndian.AppendUint16(res, r)
+		}
+		return res, nil
+
+	case writeCoil:
+		if len(data) != 4 || (n != 0 && n != 0xff00) {
+			return nil, ErrValue
+		}
+		s.coils[addr] = n != 0
+		return append(append(buf[:0], funcCode), data...), nil
+
+	case writeReg:
+		if len(data) != 4 {
+			return nil, ErrValue
+		}
+		s.holdRegs[addr] = n
+		return append(append(buf[:0], funcCode), data...), nil
+
+	case writeCoils:
+		if len(data) < 5 || n == 0 || n > 1968 || int(data[4]) != (int(n)+7)/8 || len(data) != 5+int(data[4]) {
+			return nil, ErrValue
+		}
+		if int(addr)+int(n) > 0x10000 {
+			return nil, ErrAddr
+		}
+		for i := range int(n) {
+			s.coils[int(addr)+i] = data[5+i/8]&(1<<(i%8)) != 0
+		}
+		return append(append(buf[:0], funcCode), data[:4]...), nil
+
+	case writeRegs:
+		if len(data) < 5 || n == 0 || n > 123 || int(data[4]) != 2*int(n) || len(data) != 5+int(data[4]) {
+			return nil, ErrValue
+		}
+		if int(addr)+int(n) > 0x10000 {
+			return nil, ErrAddr
+		}
+		for i := range int(n) {
+			s.holdRegs[int(addr)+i] = binary.BigEndian.Uint16(data[5+2*i:])
+		}
+		return append(append(buf[:0], funcCode), data[:4]...), nil
+
+	case maskWriteReg:
+		if len(data) != 6 {
+			return nil, ErrValue
+		}
+		andMask, orMask := n, binary.BigEndian.Uint16(data[4:6])
+		s.holdRegs[addr] = s.holdRegs[addr]&andMask | orMask&^andMask
+		return append(append(buf[:0], funcCode), data...), nil
+
+	case readWriteRegs:
+		if len(data) < 9 {
+			return nil, ErrValue
+		}
+		writeAddr := binary.BigEndian.Uint16(data[4:6])
+		writeN := binary.BigEndian.Uint16(data[6:8])
+		if n == 0 || n > 125 || writeN == 0 || writeN > 121 || int(data[8]) != 2*int(writeN) || len(data) != 9+int(data[8]) {
+			return nil, ErrValue
+		}
+		if int(addr)+int(n) > 0x10000 || int(writeAddr)+int(writeN) > 0x10000 {
+			return nil, ErrAddr
+		}
+		// write before read conform specification
+		for i := range int(writeN) {
+			s.holdRegs[int(writeAddr)+i] = binary.BigEndian.Uint16(data[9+2*i:])
+		}
+		res := append(buf[:0], funcCode, byte(2*n))
+		for _, r := range s.holdRegs[addr : int(addr)+int(n)] {
+			res = binary.BigEndian.AppendUint16(res, r)
+		}
+		return res, nil
+
+	case diagnostics:
+		// “Return Query Data” only
+		if len(data) < 2 || binary.BigEndian.Uint16(data) != 0 {
+			return nil, ErrFunc
+		}
+		return append(append(buf[:0], funcCode), data...), nil
+	}
+	return nil, ErrFunc
+}
@@ -0,0 +1,56 @@
+package modbus_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pascaldekloe/modbus"
+)
+
+func TestServerSetException(t *testing.T) {
+	server, client := testServerClient(t)
+
+	server.SetException(0x03, 110, modbus.ErrBusy)
+	var buf [10]uint16
+	if err := client.ReadHoldRegs(buf[:], 101); !errors.Is(err, modbus.ErrBusy) {
+		t.Errorf("got error %v for range with address 110, want ErrBusy", err)
+	}
+	if err := client.ReadHoldRegs(buf[:], 111); err != nil {
+		t.Errorf("got error %v for range without address 110", err)
+	}
+	if _, err := client.ReadInputReg(110); err != nil {
+		t.Errorf("got error %v for other function code", err)
+	}
+
+	server.SetException(0x03, 110, 0)
+	if err := client.ReadHoldRegs(buf[:], 101); err != nil {
+		t.Errorf("got error %v after removal", err)
+	}
+}
+
+func TestServerConformanceClass(t *testing.T) {
+	server, client := testServerClient(t)
+	server.SetHoldReg(0, 42)
+	server.SetHoldReg(0xffff, 7)
+	server.SetCoil(0, true)
+
+	// no read exception status (0x07)
+	class, err := client.ConformanceClass()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if class != 0 {
+		t.Errorf("got class %d, want 0", class)
+	}
+
+	// probes have no effect
+	if got := server.HoldReg(0); got != 42 {
+		t.Errorf("got holding register 0 value %d after probes, want 42", got)
+	}
+	if got := server.HoldReg(0xffff); got != 7 {
+		t.Errorf("got holding register 0xFFFF value %d after probes, want 7", got)
+	}
+	if !server.Coil(0) {
+		t.Error("coil 0 off after probes")
+	}
+}
@@ -0,0 +1,75 @@
+package modbus
+
+import "sync"
+
+// SyncClient serializes transactions on a TCPClient for use from within
+// multiple goroutines. Each call waits its turn.
+type SyncClient struct {
+	// Transport is the SyncClient itself.
+	Client
+
+	mutex sync.Mutex // guards tcp
+	tcp   *TCPClient
+}
+
+// NewSyncClient returns a wrapper of c. Any use of c other than through the
+// wrapper should stop.
+func NewSyncClient(c *TCPClient) *SyncClient {
+	s := &SyncClient{tcp: c}
+	s.Client = Client{s}
+	return s
+}
+
+// Exchange implements the Transport interface. The response is a copy, i.e.,
+// it remains valid after subsequent use.
+func (s *SyncClient) Exchange(funcCode byte, req []byte) ([]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	res, err := s.tcp.Exchange(funcCode, req)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{}, res...), nil
+}
+
+// Do runs f with exclusive access to the TCPClient, for the functionality not
+// available through Client. The TCPClient must not be retained after f.
+func (s *SyncClient) Do(f func(c *TCPClient) error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return f(s.tcp)
+}
+
+// UnitClient is a view on a SyncClient which addresses another unit
+// identifier, such as a serial device behind a gateway. Views share the
+// connection, and they are safe for concurrent use.
+type UnitClient struct {
+	// Transport is the UnitClient itself.
+	Client
+
+	sync   *SyncClient
+	unitID byte
+}
+
+// WithUnit returns a view which addresses another unit identifier. UnitID of
+// the TCPClient remains unaffected.
+func (s *SyncClient) WithUnit(unitID byte) *UnitClient {
+	u := &UnitClient{sync: s, unitID: unitID}
+	u.Client = Client{u}
+	return u
+}
+
+// UnitID returns the unit identifier addressed.
+func (u *UnitClient) UnitID() byte { return u.unitID }
+
+// Exchange implements the Transport interface. The response is a copy, i.e.,
+// it remains valid after subsequent use.
+func (u *UnitClient) Exchange(funcCode byte, req []byte) ([]byte, error) {
+	u.sync.mutex.Lock()
+	defer u.sync.mutex.Unlock()
+	res, err := unitTransport{u.sync.tcp, u.unitID}.Exchange(funcCode, req)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{}, res...), nil
+}
@@ -0,0 +1,78 @@
+package modbus
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSyncClient(t *testing.T) {
+	// registers hold their address
+	s := NewSyncClient(&TCPClient{
+		Conn: &fakeDevice{respond: func(req []byte) []byte {
+			return responseFrame(req, 2, req[8], req[9])
+		}},
+		UnitID: 1,
+	})
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for addr := uint16(i * 100); addr < uint16(i*100+50); addr++ {
+				got, err := s.ReadHoldReg(addr)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if got != addr {
+					t.Errorf("got register %d value %d", addr, got)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	var txN uint64
+	s.Do(func(c *TCPClient) error {
+		txN = c.TxN
+		return nil
+	})
+	if txN != 8*50 {
+		t.Errorf("got TxN %d, want %d", txN, 8*50)
+	}
+}
+
+func TestSyncClientWithUnit(t *testing.T) {
+	// registers hold the unit identifier
+	s := NewSyncClient(&TCPClient{
+		Conn: &fakeDevice{respond: func(req []byte) []byte {
+			return responseFrame(req, 2, 0, req[6])
+		}},
+		UnitID: 1,
+	})
+
+	var wg sync.WaitGroup
+	for unitID := byte(2); unitID < 10; unitID++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			u := s.WithUnit(unitID)
+			for range 50 {
+				got, err := u.ReadHoldReg(100)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if got != uint16(unitID) {
+					t.Errorf("got %d from unit %d", got, unitID)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got, err := s.ReadHoldReg(100); err != nil || got != 1 {
+		t.Errorf("got (%d, %v) from default unit, want (1, nil)", got, err)
+	}
+}
@@ -1,15 +1,31 @@
 package modbus
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"math"
+	"math/big"
+	"math/rand/v2"
 	"net"
+	"sync/atomic"
 	"time"
 )
 
+// ErrNoConn denies a reconnect on NoReconnect.
+var ErrNoConn = errors.New("Modbus connection lost; explicit Connect required")
+
+// ErrCircuitOpen denies transactions on a TCPClient with too many consecutive
+// failures. See BreakAfter and ReconnectBackoff for details.
+var ErrCircuitOpen = errors.New("Modbus circuit breaker open")
+
+// ErrPaused is the conventional return of BeforeTx to skip transactions.
+var ErrPaused = errors.New("Modbus transactions paused")
+
 // TCPDial establishes a connection for fail-fast behaviour. The unit-identifier
 // can be adjusted after TCPDial when needed.
 func TCPDial(addr string, timeout time.Duration) (*TCPClient, error) {
@@ -27,6 +43,83 @@ func TCPDial(addr string, timeout time.Duration) (*TCPClient, error) {
 	return c, nil
 }
 
+// TLSDial is like TCPDial, yet with Modbus/TCP Security, i.e., TLS on port 802
+// conventionally. Mutual authentication needs a client certificate in config.
+// Reconnects use the same configuration. The timeout applies to both the TCP
+// connect and the TLS handshake.
+func TLSDial(addr string, config *tls.Config, timeout time.Duration) (*TCPClient, error) {
+	c := &TCPClient{
+		RemoteAddr: addr,
+		TxTimeout:  timeout,
+		tlsConfig:  config,
+		// correct default
+		UnitID: 0xff,
+	}
+
+	err := c.ensureConn()
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewTCPClient wraps an established connection, such as one over TLS or a
+// Unix domain socket. Reconnects are disabled with NoReconnect, as the client
+// has no means to recreate conn. Set DialFunc, and clear NoReconnect, for the
+// automated recovery.
+func NewTCPClient(conn net.Conn, unitID byte) *TCPClient {
+	return &TCPClient{
+		Conn:        conn,
+		NoReconnect: true,
+		UnitID:      unitID,
+	}
+}
+
+// DialConfig has the options for TCPDialConfig.
+type DialConfig struct {
+	// Specify the <host>:<port> to connect with.
+	Addr string
+
+	// Limit the time for connection establishment, which includes any
+	// reconnects. The zero value omits timeout protection.
+	ConnectTimeout time.Duration
+
+	// Limit the time for a request–response pair on connection level.
+	// The zero value omits timeout protection.
+	TxTimeout time.Duration
+
+	// Optional dial settings, such as a local address or dual-stack
+	// options. The Timeout of the Dialer is overruled by ConnectTimeout
+	// when not zero.
+	Dialer *net.Dialer
+}
+
+// TCPDialConfig is like TCPDial, yet with the connect timeout apart from the
+// transaction timeout.
+func TCPDialConfig(cfg DialConfig) (*TCPClient, error) {
+	d := net.Dialer{KeepAlive: -1} // disabled
+	if cfg.Dialer != nil {
+		d = *cfg.Dialer
+	}
+	if cfg.ConnectTimeout != 0 {
+		d.Timeout = cfg.ConnectTimeout
+	}
+
+	c := &TCPClient{
+		RemoteAddr: cfg.Addr,
+		Dialer:     &d,
+		TxTimeout:  cfg.TxTimeout,
+		// correct default
+		UnitID: 0xff,
+	}
+
+	err := c.ensureConn()
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
 // TCPClient manages a connection for use from within a single goroutine.
 // Transactions are dealt with sequentially—only one request at a time.
 //
@@ -34,31 +127,354 @@ func TCPDial(addr string, timeout time.Duration) (*TCPClient, error) {
 // Errors fatal to the connection cause an automated Close, which includes the
 // reset to nil.
 type TCPClient struct {
+	// The counters are updated atomically. Keep first in struct for 64-bit
+	// alignment on 32-bit platforms. See Stats for concurrent use.
+	TxN    uint64 // read-only transaction counter
+	RetryN uint64 // read-only retry counter
+	FragN  uint64 // read-only packet-fragmentation counter (should be low if any)
+	// round-trip times in nanoseconds, also atomic
+	lastRTT, avgRTT, maxRTT int64
+
 	// Buf is (re)used for both reading and writing. The function code
 	// starts at the 8th byte, right after its 7-byte MBAP-header.
-	// Keep first in struct for memory alignment.
-	buf [7 + 253]byte
+	buf [MaxADUSize]byte
 
 	// Specify the <host>:<port> to connect with.
 	RemoteAddr string
 
+	// Optional dial settings. Nil defaults to TxTimeout as the connect
+	// timeout, with TCP keep-alive disabled.
+	Dialer *net.Dialer
+
+	// Optional connection factory, which replaces the dial to RemoteAddr,
+	// including Dialer. Use for custom transports such as SSH tunnels.
+	DialFunc func() (net.Conn, error)
+
 	// Nil implies no connection.
 	net.Conn
 
+	// Disable the automated reconnect after failure. Calls get ErrNoConn
+	// instead, until Connect succeeds.
+	NoReconnect bool
+
+	// Circuit breaker opens after BreakAfter consecutive connection failures,
+	// i.e., transactions which fail on a dial, or which lose the connection.
+	// Exceptions reset the count, and so does success. Denials without any
+	// submission, such as ErrLimit, and context aborts are not counted.
+	// Transactions fail fast with ErrCircuitOpen for the BreakFor duration.
+	// Then, a single probe is permitted to either close the circuit, or to
+	// open it again. Zero BreakAfter disables the circuit breaker.
+	BreakAfter int
+	BreakFor   time.Duration
+
+	// Optional delay between dial attempts after failure. Transactions
+	// fail fast with ErrCircuitOpen in the meantime.
+	ReconnectBackoff ReconnectBackoff
+
 	// Limit the time for a request–response pair on connection level.
 	// The zero value omits timeout protection.
 	TxTimeout time.Duration
 
-	// read-only transaction counter
-	TxN uint64
+	// Optional deadline policy which overrides TxTimeout as soon as any
+	// latency was observed.
+	AdaptiveTimeout *AdaptiveTimeout
+
+	// Some devices need a throwaway read after each (re)connect. WarmupN
+	// holding registers at WarmupAddr are read before the first transaction
+	// on a new connection. The result is discarded, and so is any error, as
+	// the pending transaction proceeds regardless. WarmupN is capped to 125.
+	// Zero WarmupN disables the feature.
+	WarmupAddr uint16
+	WarmupN    int
+
+	// Pause between a write and its read-back for the verified writes.
+	// Some devices apply updates asynchronously.
+	VerifyDelay time.Duration
 
-	// read-only packet-fragmentation counter (should be low if any)
-	FragN uint64
+	// Pause after each write, including broadcasts and the combined read
+	// and write of function code 0x17, before the next transaction may
+	// start. Some devices reject requests while they commit an update.
+	PostWriteDelay time.Duration
+
+	// Optional retries for transient failures. Calls may override the
+	// policy with a CallOption.
+	Retry RetryPolicy
+
+	// Optional hook on packet fragmentation, with the size of the response
+	// frame, and the number of bytes received at the time of detection.
+	OnFrag func(frameLen, readN int)
+
+	// Optional hooks around each transaction attempt, for tracing and
+	// metrics. The address is zero for function codes without one. The
+	// byte counts include the MBAP header. Retries get a pair of calls
+	// each, and so do transactions which fail on the connection.
+	OnTxStart func(funcCode byte, addr uint16)
+	OnTxEnd   func(funcCode byte, addr uint16, reqN, resN int, err error)
 
 	// The unit identifier is supposed to be 0xFF with TCP.
 	// Broadcast address 0x00 “is also accepted”. In practice,
 	// quite a few devices out there only respond to 0x01.
 	UnitID byte
+
+	// Optional gate before each transaction. Any error in return cancels
+	// the transaction, and it propagates to the caller as is. See ErrPaused.
+	BeforeTx func() error
+
+	// Optional transaction wrappers, with the first as the outermost.
+	Interceptors []Interceptor
+
+	// Some noncompliant devices respond with another function code than
+	// the one requested. Such responses are accepted, with a warning, when
+	// set. Exceptions still require a function code match.
+	TolerateFuncCodeMismatch bool
+
+	// Reads to broadcast address 0x00 are denied with ErrBroadcastRead
+	// unless explicitly allowed.
+	AllowBroadcastRead bool
+
+	// Optional destination for warnings. Nil discards them.
+	Logger *slog.Logger
+
+	// details from the most recent transaction
+	last txRecord
+
+	// completion of the last write with PostWriteDelay
+	writeDone time.Time
+
+	// overrides TxTimeout when not zero
+	txDeadline time.Time
+
+	// optional binding from WithContext
+	ctx context.Context
+
+	// connection reset by fail
+	failed bool
+
+	// response reception omitted, with unit identifier 0, from broadcast
+	broadcasting bool
+
+	// consecutive connection failures for BreakAfter
+	failN     int
+	openUntil time.Time
+
+	// dial failures plus connection resets by fail
+	connFailN uint64
+
+	// optional Modbus/TCP Security from TLSDial
+	tlsConfig *tls.Config
+
+	// consecutive dial failures for ReconnectBackoff
+	dialFailN int
+	redialAt  time.Time
+}
+
+// CircuitState is the status of the circuit breaker. See BreakAfter.
+type CircuitState uint8
+
+// Circuit States
+const (
+	CircuitClosed   CircuitState = iota // transactions permitted
+	CircuitOpen                         // transactions fail fast
+	CircuitHalfOpen                     // probe permitted
+)
+
+// String returns the name in lower case.
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", uint8(s))
+}
+
+// CircuitState returns the current status of the circuit breaker. The return
+// is CircuitClosed when BreakAfter is zero.
+func (c *TCPClient) CircuitState() CircuitState {
+	switch {
+	case c.BreakAfter <= 0 || c.failN < c.BreakAfter:
+		return CircuitClosed
+	case time.Now().Before(c.openUntil):
+		return CircuitOpen
+	}
+	return CircuitHalfOpen
+}
+
+// ReconnectBackoff spaces out dial attempts. The delay after the first failure
+// is Initial, and each consecutive failure multiplies the delay, up to Max.
+// Zero Initial disables the backoff.
+type ReconnectBackoff struct {
+	Initial, Max time.Duration
+
+	// Growth per consecutive failure. Zero defaults to 2.
+	Multiplier float64
+
+	// Randomization as a fraction of the delay, e.g., 0.2 for ±20 %.
+	Jitter float64
+}
+
+// Delay returns the wait after failN consecutive failures.
+func (b *ReconnectBackoff) delay(failN int) time.Duration {
+	multiplier := b.Multiplier
+	if multiplier == 0 {
+		multiplier = 2
+	}
+	d := float64(b.Initial) * math.Pow(multiplier, float64(failN-1))
+	if b.Max != 0 {
+		d = min(d, float64(b.Max))
+	}
+	if b.Jitter != 0 {
+		d *= 1 + b.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(d)
+}
+
+// RetryPolicy configures the repetition of failed transactions. Only requests
+// which are idempotent qualify by default. Exceptions count as transient conform
+// Temporary, and so do connection failures, which include a reconnect.
+type RetryPolicy struct {
+	// Maximum number of attempts per transaction. Values below 2 disable
+	// retries.
+	MaxAttempts int
+
+	// Pause before each retry.
+	Backoff time.Duration
+
+	// Writes opt-in to retries for updates, which may be applied twice.
+	Writes bool
+}
+
+// CallOption customizes a single call, without any effect on the TCPClient.
+type CallOption func(*callConfig)
+
+// CallConfig has the settings of a call.
+type callConfig struct {
+	retry RetryPolicy
+}
+
+// WithRetries sets the maximum number of attempts for a call, in place of the
+// MaxAttempts of the Retry field. The option includes writes, as it is an
+// explicit opt-in per call. Backoff remains as configured in the Retry field.
+//
+//	err := client.WriteReg(1001, 7, modbus.WithRetries(5))
+func WithRetries(maxAttempts int) CallOption {
+	return func(cfg *callConfig) {
+		cfg.retry.MaxAttempts = maxAttempts
+		cfg.retry.Writes = true
+	}
+}
+
+// WithRetryPolicy sets the retry policy for a call, in place of the Retry
+// field.
+func WithRetryPolicy(p RetryPolicy) CallOption {
+	return func(cfg *callConfig) {
+		cfg.retry = p
+	}
+}
+
+// TxFunc does a transaction. Both the request and the response are PDU data,
+// i.e., the bytes which follow the function code. Response bytes may stop being
+// valid at the next invocation to the TCPClient.
+type TxFunc func(funcCode byte, req []byte) (res []byte, err error)
+
+// Interceptor wraps transactions for cross-cutting concerns such as logging,
+// metrics, retries and rate limiting. Implementations invoke next to proceed,
+// which may be done more than once. The request is a copy, i.e., it remains
+// valid throughout the transaction, regardless of any responses.
+type Interceptor func(next TxFunc) TxFunc
+
+// TxDetail has the details of a transaction.
+type TxDetail struct {
+	TxID       uint16        // transaction identifier
+	FuncCode   byte          // function code requested
+	Request    []byte        // request PDU, including the function code
+	Response   []byte        // response PDU, if any, including the function code
+	Fragmented bool          // response split over multiple packets
+	Duration   time.Duration // request submission until response completion
+}
+
+// TxRecord holds TxDetail without allocation.
+type txRecord struct {
+	txID       uint16
+	funcCode   byte
+	fragmented bool
+	duration   time.Duration
+	reqN, resN int
+	req, res   [MaxPDUSize]byte
+}
+
+// LastTransaction returns the details of the most recent transaction. The
+// slices in return are copies, i.e., they are not affected by any subsequent
+// use of the TCPClient. The zero TxDetail is returned when no transaction has
+// been attempted yet.
+func (c *TCPClient) LastTransaction() TxDetail {
+	if c.last.reqN == 0 {
+		return TxDetail{}
+	}
+	return TxDetail{
+		TxID:       c.last.txID,
+		FuncCode:   c.last.funcCode,
+		Request:    append([]byte(nil), c.last.req[:c.last.reqN]...),
+		Response:   append([]byte(nil), c.last.res[:c.last.resN]...),
+		Fragmented: c.last.fragmented,
+		Duration:   c.last.duration,
+	}
+}
+
+// Stats is a snapshot of the TCPClient counters.
+type Stats struct {
+	TxN    uint64 // transaction count
+	RetryN uint64 // retry count
+	FragN  uint64 // packet-fragmentation count
+
+	// Round-trip times span from request submission until response
+	// completion. Only transactions with a response, including exception
+	// responses, are observed. The average is exponentially weighted, with
+	// 1/8 for each observation, conform the smoothed RTT of TCP.
+	LastRTT, AvgRTT, MaxRTT time.Duration
+}
+
+// Stats returns the counters. The method is safe for concurrent use, including
+// during transactions.
+func (c *TCPClient) Stats() Stats {
+	return Stats{
+		TxN:    atomic.LoadUint64(&c.TxN),
+		RetryN: atomic.LoadUint64(&c.RetryN),
+		FragN:  atomic.LoadUint64(&c.FragN),
+
+		LastRTT: time.Duration(atomic.LoadInt64(&c.lastRTT)),
+		AvgRTT:  time.Duration(atomic.LoadInt64(&c.avgRTT)),
+		MaxRTT:  time.Duration(atomic.LoadInt64(&c.maxRTT)),
+	}
+}
+
+// ResetStats zeroes the counters. The method is safe for concurrent use,
+// including during transactions.
+func (c *TCPClient) ResetStats() {
+	atomic.StoreUint64(&c.TxN, 0)
+	atomic.StoreUint64(&c.RetryN, 0)
+	atomic.StoreUint64(&c.FragN, 0)
+	atomic.StoreInt64(&c.lastRTT, 0)
+	atomic.StoreInt64(&c.avgRTT, 0)
+	atomic.StoreInt64(&c.maxRTT, 0)
+}
+
+// ObserveRTT updates the round-trip times of Stats.
+func (c *TCPClient) observeRTT(d time.Duration) {
+	atomic.StoreInt64(&c.lastRTT, int64(d))
+	if int64(d) > atomic.LoadInt64(&c.maxRTT) {
+		atomic.StoreInt64(&c.maxRTT, int64(d))
+	}
+	avg := atomic.LoadInt64(&c.avgRTT)
+	if avg == 0 {
+		avg = int64(d)
+	} else {
+		avg += (int64(d) - avg) / 8
+	}
+	atomic.StoreInt64(&c.avgRTT, avg)
 }
 
 // Close and zero the connection, if any.
@@ -71,8 +487,34 @@ func (c *TCPClient) Close() error {
 	return err
 }
 
+// CloseContext is like Close, yet it awaits outstanding transactions for as
+// long as ctx permits. Transactions are sequential on a TCPClient. Thus, there
+// is nothing to await when called from the owning goroutine.
+func (c *TCPClient) CloseContext(ctx context.Context) error {
+	return c.Close()
+}
+
+// SetTxDeadline sets an absolute deadline for transactions, which overrides
+// both TxTimeout and AdaptiveTimeout. The deadline remains in effect until it
+// is reset with the zero Time.
+//
+//	c.SetTxDeadline(time.Now().Add(time.Minute))
+//	defer c.SetTxDeadline(time.Time{})
+func (c *TCPClient) SetTxDeadline(t time.Time) {
+	c.txDeadline = t
+}
+
+// Warn logs a message to the Logger, if any.
+func (c *TCPClient) warn(msg string, args ...any) {
+	if c.Logger != nil {
+		c.Logger.Warn(msg, args...)
+	}
+}
+
 // Fail the connection with a reset.
 func (c *TCPClient) fail(cause error) error {
+	c.failed = true
+	c.connFailN++
 	err := c.Close()
 	if err != nil {
 		return errors.Join(cause, err)
@@ -80,27 +522,97 @@ func (c *TCPClient) fail(cause error) error {
 	return cause
 }
 
+// Connect establishes a connection, if not connected already. Use is optional
+// unless NoReconnect is set.
+func (c *TCPClient) Connect() error {
+	c.failed = false
+	c.redialAt = time.Time{}
+	return c.ensureConn()
+}
+
+// Resync discards any pending bytes on the connection, such as a late
+// response from a transaction which timed out. Reads continue until no data
+// arrives within a short wait of 20 ms. Errors other than the timeout are
+// fatal to the connection. Resync is a no-op when not connected.
+func (c *TCPClient) Resync() error {
+	if c.Conn == nil {
+		return nil
+	}
+	defer c.Conn.SetReadDeadline(time.Time{})
+
+	for {
+		err := c.Conn.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+		if err != nil {
+			err = fmt.Errorf("timeout on Modbus connection needed: %w", err)
+			return c.fail(err)
+		}
+		_, err = c.Conn.Read(c.buf[:])
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return nil // drained
+			}
+			err = fmt.Errorf("Modbus resync: %w", err)
+			return c.fail(err)
+		}
+	}
+}
+
 // EnsureConn creates a connection when not connected.
 func (c *TCPClient) ensureConn() error {
 	if c.Conn != nil {
 		return nil
 	}
+	if c.failed && c.NoReconnect {
+		return ErrNoConn
+	}
+	if c.ReconnectBackoff.Initial > 0 && time.Now().Before(c.redialAt) {
+		return ErrCircuitOpen
+	}
 
-	d := net.Dialer{
-		Timeout:   c.TxTimeout,
-		KeepAlive: -1, // disabled
+	d := c.Dialer
+	if d == nil {
+		d = &net.Dialer{
+			Timeout:   c.TxTimeout,
+			KeepAlive: -1, // disabled
+		}
+	}
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var conn net.Conn
+	var err error
+	switch {
+	case c.DialFunc != nil:
+		conn, err = c.DialFunc()
+	case c.tlsConfig != nil:
+		// handshake included
+		tlsDialer := tls.Dialer{NetDialer: d, Config: c.tlsConfig}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", c.RemoteAddr)
+	default:
+		conn, err = d.DialContext(ctx, "tcp", c.RemoteAddr)
 	}
-	conn, err := d.Dial("tcp", c.RemoteAddr)
 	if err != nil {
+		c.connFailN++
+		if c.ReconnectBackoff.Initial > 0 {
+			c.dialFailN++
+			c.redialAt = time.Now().Add(c.ReconnectBackoff.delay(c.dialFailN))
+		}
 		return err
 	}
+	c.dialFailN = 0
 
-	err = trimTCPConn(conn)
-	if err != nil {
-		return errors.Join(err, conn.Close())
+	if c.DialFunc == nil && c.tlsConfig == nil {
+		// TLS records exceed the Modbus footprint
+		err = trimTCPConn(conn)
+		if err != nil {
+			return errors.Join(err, conn.Close())
+		}
 	}
 
 	c.Conn = conn
+	c.failed = false
 	return nil
 }
 
@@ -118,35 +630,109 @@ func trimTCPConn(conn net.Conn) error {
 }
 
 // ReadInputReg fetches an input register at the given address.
-func (c *TCPClient) ReadInputReg(addr uint16) (uint16, error) {
-	return c.readReg(addr, readInputRegs)
+func (c *TCPClient) ReadInputReg(addr uint16, opts ...CallOption) (uint16, error) {
+	return c.readReg(addr, readInputRegs, opts...)
 }
 
 // ReadHoldReg fetches a holding register at the given address.
-func (c *TCPClient) ReadHoldReg(addr uint16) (uint16, error) {
-	return c.readReg(addr, readHoldRegs)
+func (c *TCPClient) ReadHoldReg(addr uint16, opts ...CallOption) (uint16, error) {
+	return c.readReg(addr, readHoldRegs, opts...)
 }
 
-func (c *TCPClient) readReg(addr uint16, funcCode byte) (uint16, error) {
-	err := c.readNRegs(1, addr, funcCode)
+// ReadInputRegInt16 fetches an input register at the given address as a
+// signed value in two's complement.
+func (c *TCPClient) ReadInputRegInt16(addr uint16) (int16, error) {
+	r, err := c.readReg(addr, readInputRegs)
+	return int16(r), err
+}
+
+// ReadHoldRegInt16 fetches a holding register at the given address as a
+// signed value in two's complement.
+func (c *TCPClient) ReadHoldRegInt16(addr uint16) (int16, error) {
+	r, err := c.readReg(addr, readHoldRegs)
+	return int16(r), err
+}
+
+// ReadInputRegInt32 fetches two input registers at a start address as a signed
+// value, conform RegPairInt32.
+func (c *TCPClient) ReadInputRegInt32(startAddr uint16) (int32, error) {
+	return c.readInt32(startAddr, readInputRegs)
+}
+
+// ReadHoldRegInt32 fetches two holding registers at a start address as a
+// signed value, conform RegPairInt32.
+func (c *TCPClient) ReadHoldRegInt32(startAddr uint16) (int32, error) {
+	return c.readInt32(startAddr, readHoldRegs)
+}
+
+func (c *TCPClient) readInt32(startAddr uint16, funcCode byte) (int32, error) {
+	err := c.readNRegs(2, startAddr, funcCode)
+	if err != nil {
+		return 0, err
+	}
+	return RegPairInt32((*[4]byte)(c.buf[9:13])), nil
+}
+
+// ReadInputRegInt64 fetches four input registers at a start address as a
+// signed value, conform RegQuadInt64.
+func (c *TCPClient) ReadInputRegInt64(startAddr uint16) (int64, error) {
+	return c.readInt64(startAddr, readInputRegs)
+}
+
+// ReadHoldRegInt64 fetches four holding registers at a start address as a
+// signed value, conform RegQuadInt64.
+func (c *TCPClient) ReadHoldRegInt64(startAddr uint16) (int64, error) {
+	return c.readInt64(startAddr, readHoldRegs)
+}
+
+func (c *TCPClient) readInt64(startAddr uint16, funcCode byte) (int64, error) {
+	err := c.readNRegs(4, startAddr, funcCode)
+	if err != nil {
+		return 0, err
+	}
+	return RegQuadInt64((*[8]byte)(c.buf[9:17])), nil
+}
+
+// ReadHoldRegAny fetches a holding register from the first address available.
+// Addresses are tried in order, with ErrAddr and ErrFunc as the cue for the
+// next one. The last error applies when none of the addresses succeeds.
+func (c *TCPClient) ReadHoldRegAny(addrs ...uint16) (uint16, error) {
+	var err error = ErrAddr
+	for _, addr := range addrs {
+		var v uint16
+		v, err = c.ReadHoldReg(addr)
+		if err == nil || !(errors.Is(err, ErrAddr) || errors.Is(err, ErrFunc)) {
+			return v, err
+		}
+	}
+	return 0, err
+}
+
+func (c *TCPClient) readReg(addr uint16, funcCode byte, opts ...CallOption) (uint16, error) {
+	err := c.readNRegs(1, addr, funcCode, opts...)
 	if err != nil {
 		return 0, err
 	}
 	return binary.BigEndian.Uint16(c.buf[9:11]), nil
 }
 
-func (c *TCPClient) readNRegs(n int, startAddr uint16, funcCode byte) error {
+func (c *TCPClient) readNRegs(n int, startAddr uint16, funcCode byte, opts ...CallOption) error {
+	err := checkAddrRange(startAddr, n)
+	if err != nil {
+		return err
+	}
+
 	// compose request
 	binary.BigEndian.PutUint32(c.buf[8:12], uint32(startAddr)<<16|uint32(n))
 
-	readN, err := c.sendAndReceive(c.buf[:12], funcCode)
+	readN, err := c.sendAndReceive(c.buf[:12], funcCode, opts...)
 	if err != nil {
 		return err
 	}
 
 	if int(uint(c.buf[8])) != n*2 {
-		return fmt.Errorf("Modbus repsonse with %d-byte payload for a %d-register request",
-			c.buf[1], n)
+		return fmt.Errorf("Modbus response with %d-byte payload for a %d-register request",
+			c.buf[8], n)
 	}
 	if readN != 9+n*2 {
 		return errFrameFit
@@ -157,82 +743,353 @@ func (c *TCPClient) readNRegs(n int, startAddr uint16, funcCode byte) error {
 // SendAndReceive writes the frame header plus function code in c.buf[:8] before
 // submission. The req slice must include c.buf[:8] as such. The read count also
 // includes the frame header.
-func (c *TCPClient) sendAndReceive(req []byte, funcCode byte) (readN int, err error) {
-	err = c.ensureConn()
-	if err != nil {
-		return 0, err
+func (c *TCPClient) sendAndReceive(req []byte, funcCode byte, opts ...CallOption) (readN int, err error) {
+	if c.BeforeTx != nil {
+		err = c.BeforeTx()
+		if err != nil {
+			return 0, err
+		}
 	}
 
-	c.TxN++
-
-	if c.TxTimeout != 0 {
-		err := c.Conn.SetDeadline(time.Now().Add(c.TxTimeout))
+	// request details get lost on response reception
+	reqErr := RequestError{
+		FuncCode: funcCode,
+		Addr:     reqAddr(req, funcCode),
+		UnitID:   c.txUnitID(),
+	}
+	defer func() {
 		if err != nil {
-			err = fmt.Errorf("timeout on Modbus connection needed: %w", err)
-			return 0, c.fail(err)
+			reqErr.Err = err
+			err = &reqErr
 		}
+	}()
 
+	if c.BreakAfter > 0 {
+		if c.failN >= c.BreakAfter && time.Now().Before(c.openUntil) {
+			return 0, ErrCircuitOpen
+		}
+		connFailN := c.connFailN
 		defer func() {
-			err := c.Conn.SetDeadline(time.Time{})
-			if err != nil { // probably never
-				log.Println("timeout on Modbus connection got stuck:", err)
+			switch {
+			case err == nil, errors.As(err, new(Exception)):
+				c.failN = 0 // response received
+			case c.connFailN != connFailN && (c.ctx == nil || c.ctx.Err() == nil):
+				c.failN++
+				if c.failN >= c.BreakAfter {
+					c.openUntil = time.Now().Add(c.BreakFor)
+				}
 			}
 		}()
 	}
 
-	// See “MBAP Header description” from chapter 3.1.3 of “MODBUS Messaging
-	// on TCP/IP Implementation Guide V1.0b” for the specification.
-	var reqHead uint64
-	// 2-byte transaction identifier taken from LSB of counter:
-	reqHead |= c.TxN << 48
-	// 2-byte protocol identifier remains zero for Modbus
-	// …
-	// 2-byte size of what follows:
-	reqHead |= uint64(uint64(len(req))-6) << 16
-	// 1-byte unit identifier:
-	reqHead |= uint64(c.UnitID) << 8
-	// 1-byte function code:
-	reqHead |= uint64(funcCode)
+	cfg := callConfig{retry: c.Retry}
+	for _, o := range opts {
+		o(&cfg)
+	}
+	attemptN := 1
+	if cfg.retry.MaxAttempts > 1 && (cfg.retry.Writes || isIdempotent(funcCode)) {
+		attemptN = cfg.retry.MaxAttempts
+	}
+	if attemptN == 1 {
+		return c.intercept(req, funcCode)
+	}
 
-	binary.BigEndian.PutUint64(c.buf[:8], reqHead)
+	// responses overwrite the request in c.buf
+	var pending [len(c.buf)]byte
+	copy(pending[:], req[8:])
+	for attempt := 1; ; attempt++ {
+		readN, err = c.intercept(req, funcCode)
+		if err == nil || attempt >= attemptN || !c.retryable(err) {
+			return readN, err
+		}
 
-	_, err = c.Write(req)
-	if err != nil {
-		err = fmt.Errorf("Modbus request submission: %w", err)
-		return 0, c.fail(err)
+		atomic.AddUint64(&c.RetryN, 1)
+		if cfg.retry.Backoff > 0 {
+			time.Sleep(cfg.retry.Backoff)
+		}
+		copy(req[8:], pending[:])
 	}
+}
 
-	readN, err = io.ReadAtLeast(c.Conn, c.buf[:], 9)
-	if err != nil {
-		err = fmt.Errorf("Modbus response unavailable: %w", err)
-		return readN, c.fail(err)
+// Retryable returns whether err is considered to be transient.
+func (c *TCPClient) retryable(err error) bool {
+	if c.ctx != nil && c.ctx.Err() != nil {
+		return false
 	}
-	resHead := binary.BigEndian.Uint64(c.buf[:8])
-
-	// The transaction, protocol and unit identifier all must equal the
-	// request's. The function code in return may include an error flag.
-	const sizeMask = 0xffff << 16
-	switch resHead &^ sizeMask {
-	case reqHead &^ sizeMask:
-		break // regular response
+	var e Exception
+	if errors.As(err, &e) {
+		return e.Temporary()
+	}
+	return errors.As(err, new(net.Error)) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
 
-	case (reqHead &^ sizeMask) | errorFlag:
-		if readN != 9 {
-			return readN, c.fail(errFrameFit)
-		}
-		return readN, Exception(c.buf[8])
+// Intercept is sendAndReceive with Interceptors applied, if any.
+func (c *TCPClient) intercept(req []byte, funcCode byte) (readN int, err error) {
+	if len(c.Interceptors) == 0 {
+		return c.transact(req, funcCode)
+	}
 
-	default:
-		err = fmt.Errorf("Modbus response frame %#016x… does not match request frame %#016x…",
-			resHead, reqHead)
-		return readN, c.fail(err)
+	tx := TxFunc(c.txPDU)
+	for i := len(c.Interceptors) - 1; i >= 0; i-- {
+		tx = c.Interceptors[i](tx)
+	}
+	// responses overwrite the request in c.buf
+	var pending [len(c.buf)]byte
+	n := copy(pending[:], req[8:])
+	res, err := tx(funcCode, pending[:n])
+	if err != nil {
+		return 0, err
 	}
+	// response may come from another source than c.buf
+	return 8 + copy(c.buf[8:], res), nil
+}
 
-	remainLen := (resHead >> 16) & 0xffff
-	end := int(remainLen + 6)
-	switch {
-	case end == readN:
-		break // happy flow
+// TxPDU is the innermost TxFunc.
+func (c *TCPClient) txPDU(funcCode byte, req []byte) ([]byte, error) {
+	if len(req) > len(c.buf)-8 {
+		return nil, ErrLimit
+	}
+	// no-op when req is c.buf[8:] already
+	n := copy(c.buf[8:], req)
+	readN, err := c.transact(c.buf[:8+n], funcCode)
+	if err != nil || readN == 0 {
+		return nil, err // broadcast without response
+	}
+	return c.buf[8:readN], nil
+}
+
+// Exchange implements the Transport interface. Response bytes stop being valid
+// at the next invocation to the TCPClient.
+func (c *TCPClient) Exchange(funcCode byte, req []byte) ([]byte, error) {
+	if len(req) > len(c.buf)-8 {
+		return nil, ErrLimit
+	}
+	n := copy(c.buf[8:], req)
+	readN, err := c.sendAndReceive(c.buf[:8+n], funcCode)
+	if err != nil {
+		return nil, err
+	}
+	return c.buf[8:readN], nil
+}
+
+// RawPDU does a transaction with any function code, such as a vendor-specific
+// one. Both data and the return are the PDU without the function code. Unlike
+// Exchange, the return remains valid after subsequent use. The return is
+// ErrLimit when the request exceeds MaxPDUSize, function code included.
+func (c *TCPClient) RawPDU(funcCode byte, data []byte) ([]byte, error) {
+	if funcCode == 0 || funcCode&errorFlag != 0 {
+		return nil, fmt.Errorf("Modbus function code %#02x out of range", funcCode)
+	}
+	res, err := c.Exchange(funcCode, data)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), res...), nil
+}
+
+// WithUnit returns a view which addresses another unit identifier, such as a
+// device behind a gateway. The view shares the TCPClient, including its single
+// goroutine constraint, and UnitID remains unaffected.
+func (c *TCPClient) WithUnit(unitID byte) Client {
+	return Client{unitTransport{c, unitID}}
+}
+
+// UnitTransport overrides the unit identifier per Exchange.
+type unitTransport struct {
+	c      *TCPClient
+	unitID byte
+}
+
+// Exchange implements the Transport interface.
+func (t unitTransport) Exchange(funcCode byte, req []byte) ([]byte, error) {
+	defer func(restore byte) {
+		t.c.UnitID = restore
+	}(t.c.UnitID)
+	t.c.UnitID = t.unitID
+	return t.c.Exchange(funcCode, req)
+}
+
+// ReqAddr returns the (start) address of a request frame, if any.
+func reqAddr(req []byte, funcCode byte) uint16 {
+	if !hasAddr(funcCode) || len(req) < 10 {
+		return 0
+	}
+	return binary.BigEndian.Uint16(req[8:10])
+}
+
+// Transact is sendAndReceive without any of the Interceptors.
+func (c *TCPClient) transact(req []byte, funcCode byte) (readN int, err error) {
+	if c.OnTxStart != nil || c.OnTxEnd != nil {
+		addr := reqAddr(req, funcCode)
+		if c.OnTxStart != nil {
+			c.OnTxStart(funcCode, addr)
+		}
+		if c.OnTxEnd != nil {
+			reqN := len(req)
+			defer func() {
+				c.OnTxEnd(funcCode, addr, reqN, readN, err)
+			}()
+		}
+	}
+
+	if c.ctx != nil && c.ctx.Err() != nil {
+		return 0, c.ctx.Err()
+	}
+	if c.txUnitID() == 0 && !c.AllowBroadcastRead && !isWrite(funcCode) {
+		return 0, ErrBroadcastRead
+	}
+
+	if !c.writeDone.IsZero() {
+		time.Sleep(time.Until(c.writeDone.Add(c.PostWriteDelay)))
+		c.writeDone = time.Time{}
+	}
+	if c.PostWriteDelay != 0 && mutates(funcCode) {
+		defer func() {
+			c.writeDone = time.Now()
+		}()
+	}
+
+	fresh := c.Conn == nil
+	err = c.ensureConn()
+	if err != nil {
+		return 0, err
+	}
+	if fresh && c.WarmupN > 0 {
+		c.warmup(req)
+		// warmup may have failed the connection
+		err = c.ensureConn()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	txN := atomic.AddUint64(&c.TxN, 1)
+
+	timeout := c.TxTimeout
+	if c.AdaptiveTimeout != nil {
+		if d := c.AdaptiveTimeout.Timeout(); d != 0 {
+			timeout = d
+		}
+	}
+	deadline := c.txDeadline
+	if deadline.IsZero() && timeout != 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	if !deadline.IsZero() {
+		err := c.Conn.SetDeadline(deadline)
+		if err != nil {
+			err = fmt.Errorf("timeout on Modbus connection needed: %w", err)
+			return 0, c.fail(err)
+		}
+
+		conn := c.Conn // may be reset by fail
+		defer func() {
+			err := conn.SetDeadline(time.Time{})
+			if err != nil && c.Conn != nil { // probably never
+				c.warn("timeout on Modbus connection got stuck", "error", err)
+			}
+		}()
+	}
+
+	if c.ctx != nil {
+		conn := c.Conn // may be reset by fail
+		aborted := make(chan struct{})
+		stop := context.AfterFunc(c.ctx, func() {
+			// unblock any I/O in progress
+			conn.SetDeadline(time.Unix(1, 0))
+			close(aborted)
+		})
+		defer func() {
+			if stop() {
+				return
+			}
+			<-aborted
+			conn.SetDeadline(time.Time{})
+			if err != nil {
+				err = fmt.Errorf("%w: %w", c.ctx.Err(), err)
+			}
+		}()
+	}
+
+	reqHead := mbapHead(txN, len(req), c.txUnitID(), funcCode)
+	binary.BigEndian.PutUint64(c.buf[:8], reqHead)
+
+	c.last = txRecord{txID: uint16(txN), funcCode: funcCode}
+	c.last.reqN = copy(c.last.req[:], req[7:])
+	start := time.Now()
+	defer func() {
+		c.last.duration = time.Since(start)
+		if readN > 7 {
+			c.last.resN = copy(c.last.res[:], c.buf[7:readN])
+		}
+		if c.broadcasting {
+			return // no round trip
+		}
+		if err == nil || errors.As(err, new(Exception)) {
+			c.observeRTT(c.last.duration)
+		}
+	}()
+
+	if c.AdaptiveTimeout != nil && !c.broadcasting {
+		defer func() {
+			var netErr net.Error
+			switch {
+			case err == nil, errors.As(err, new(Exception)):
+				c.AdaptiveTimeout.Observe(time.Since(start))
+			case c.txDeadline.IsZero() && errors.As(err, &netErr) && netErr.Timeout():
+				// latency exceeds the deadline
+				c.AdaptiveTimeout.Observe(timeout)
+			}
+		}()
+	}
+
+	_, err = c.Write(req)
+	if err != nil {
+		err = fmt.Errorf("Modbus request submission: %w", err)
+		return 0, c.fail(err)
+	}
+	if c.broadcasting {
+		return 0, nil // no response
+	}
+
+	readN, err = io.ReadAtLeast(c.Conn, c.buf[:], 9)
+	if err != nil {
+		err = fmt.Errorf("Modbus response unavailable: %w", err)
+		return readN, c.fail(err)
+	}
+	resHead := binary.BigEndian.Uint64(c.buf[:8])
+
+	// The transaction, protocol and unit identifier all must equal the
+	// request's. The function code in return may include an error flag.
+	const sizeMask = 0xffff << 16
+	switch resHead &^ sizeMask {
+	case reqHead &^ sizeMask:
+		break // regular response
+
+	case (reqHead &^ sizeMask) | errorFlag:
+		if readN != 9 {
+			return readN, c.fail(errFrameFit)
+		}
+		return readN, Exception(c.buf[8])
+
+	default:
+		const funcMask = 0xff &^ errorFlag
+		if c.TolerateFuncCodeMismatch && (resHead^reqHead)&^(sizeMask|funcMask) == 0 {
+			c.warn("tolerated function code mismatch", "request", funcCode, "response", byte(resHead))
+			break
+		}
+
+		err = fmt.Errorf("Modbus response frame %#016x… does not match request frame %#016x…",
+			resHead, reqHead)
+		return readN, c.fail(err)
+	}
+
+	remainLen := (resHead >> 16) & 0xffff
+	end := int(remainLen + 6)
+	switch {
+	case end == readN:
+		break // happy flow
 
 	case end < readN:
 		err = errors.New("Modbus response reception exceeds frame length")
@@ -242,7 +1099,11 @@ func (c *TCPClient) sendAndReceive(req []byte, funcCode byte) (readN int, err er
 		return readN, c.fail(err)
 	default:
 		// packet fragmentation should be a rare occurrence
-		c.FragN++
+		atomic.AddUint64(&c.FragN, 1)
+		c.last.fragmented = true
+		if c.OnFrag != nil {
+			c.OnFrag(end, readN)
+		}
 
 		_, err = io.ReadFull(c.Conn, c.buf[readN:end])
 		if err != nil {
@@ -258,19 +1119,101 @@ func (c *TCPClient) sendAndReceive(req []byte, funcCode byte) (readN int, err er
 	return readN, nil
 }
 
+// MbapHead returns the frame header plus function code for a frame of frameLen
+// bytes in total.
+func mbapHead(txN uint64, frameLen int, unitID, funcCode byte) uint64 {
+	// See “MBAP Header description” from chapter 3.1.3 of “MODBUS Messaging
+	// on TCP/IP Implementation Guide V1.0b” for the specification.
+	var head uint64
+	// 2-byte transaction identifier taken from LSB of counter:
+	head |= txN << 48
+	// 2-byte protocol identifier remains zero for Modbus
+	// …
+	// 2-byte size of what follows:
+	head |= uint64(frameLen-6) << 16
+	// 1-byte unit identifier:
+	head |= uint64(unitID) << 8
+	// 1-byte function code:
+	head |= uint64(funcCode)
+	return head
+}
+
+// Warmup does the throwaway read with preservation of the pending request.
+// Failures are logged only.
+func (c *TCPClient) warmup(req []byte) {
+	// throwaway read needs a response
+	defer func(restore bool) {
+		c.broadcasting = restore
+	}(c.broadcasting)
+	c.broadcasting = false
+
+	var pending [len(c.buf)]byte
+	n := copy(pending[:], req[8:])
+	err := c.readNRegs(min(c.WarmupN, 125), c.WarmupAddr, readHoldRegs)
+	copy(c.buf[8:], pending[:n])
+	if err != nil {
+		c.warn("warmup read failed", "error", err)
+	}
+}
+
 // ReadInputRegs fetches consecutive input-registers at a start address into a
 // read buffer. The return is ErrLimit when buf is larger than 125 entries.
-func (c *TCPClient) ReadInputRegs(buf []uint16, startAddr uint16) error {
-	return c.readRegs(buf, startAddr, readInputRegs)
+func (c *TCPClient) ReadInputRegs(buf []uint16, startAddr uint16, opts ...CallOption) error {
+	return c.readRegs(buf, startAddr, readInputRegs, opts...)
 }
 
 // ReadHoldRegs fetches consecutive holding-registers at a start address into a
 // read buffer. The return is ErrLimit when buf is larger than 125 entries.
-func (c *TCPClient) ReadHoldRegs(buf []uint16, startAddr uint16) error {
-	return c.readRegs(buf, startAddr, readHoldRegs)
+func (c *TCPClient) ReadHoldRegs(buf []uint16, startAddr uint16, opts ...CallOption) error {
+	return c.readRegs(buf, startAddr, readHoldRegs, opts...)
+}
+
+// ReadHoldRegsLarge fetches consecutive holding-registers at a start address
+// into a read buffer of any size. Buffers over 125 entries are split into
+// multiple transactions. The read count n is less than len(buf) only on error.
+// The return is ErrAddrRange when the range exceeds address 0xFFFF.
+func (c *TCPClient) ReadHoldRegsLarge(buf []uint16, startAddr uint16) (n int, err error) {
+	err = checkAddrRange(startAddr, len(buf))
+	if err != nil {
+		return 0, err
+	}
+
+	for n < len(buf) {
+		chunk := buf[n:min(n+125, len(buf))]
+		err := c.readRegs(chunk, startAddr+uint16(n), readHoldRegs)
+		if err != nil {
+			return n, err
+		}
+		n += len(chunk)
+	}
+	return n, nil
+}
+
+// DetectMaxRegs probes the largest number of holding registers the device
+// accepts per read, with a binary search from 1 up to 125. The probes read at
+// address 0. Rejections with ErrValue lower the count. Any other error aborts
+// the search.
+func (c *TCPClient) DetectMaxRegs() (int, error) {
+	lo, hi := 0, 125 // lo is known to pass
+	for lo < hi {
+		n := (lo + hi + 1) / 2
+		err := c.readNRegs(n, 0, readHoldRegs)
+		switch {
+		case err == nil:
+			lo = n
+		case errors.Is(err, ErrValue):
+			hi = n - 1
+		default:
+			return 0, err
+		}
+	}
+	if lo == 0 {
+		return 0, ErrValue
+	}
+	return lo, nil
 }
 
-func (c *TCPClient) readRegs(buf []uint16, startAddr uint16, funcCode byte) error {
+func (c *TCPClient) readRegs(buf []uint16, startAddr uint16, funcCode byte, opts ...CallOption) error {
 	if len(buf) == 0 {
 		return nil // allowed
 	}
@@ -278,7 +1221,7 @@ func (c *TCPClient) readRegs(buf []uint16, startAddr uint16, funcCode byte) erro
 		return ErrLimit
 	}
 
-	err := c.readNRegs(len(buf), startAddr, funcCode)
+	err := c.readNRegs(len(buf), startAddr, funcCode, opts...)
 	if err != nil {
 		return err
 	}
@@ -320,11 +1263,201 @@ func (c *TCPClient) readNRegSlice(n int, startAddr uint16, funcCode byte) ([]byt
 	return c.buf[9 : 9+(n*2)], nil
 }
 
+// ReadFIFOQueue fetches the content of a first-in-first-out queue of registers
+// at a pointer address. The queue holds up to 31 values.
+func (c *TCPClient) ReadFIFOQueue(pointerAddr uint16) ([]uint16, error) {
+	binary.BigEndian.PutUint16(c.buf[8:10], pointerAddr)
+	readN, err := c.sendAndReceive(c.buf[:10], readFIFO)
+	if err != nil {
+		return nil, err
+	}
+
+	if readN < 12 {
+		return nil, errFrameFit
+	}
+	byteCount := int(binary.BigEndian.Uint16(c.buf[8:10]))
+	fifoCount := int(binary.BigEndian.Uint16(c.buf[10:12]))
+	if fifoCount > 31 {
+		return nil, fmt.Errorf("Modbus FIFO count %d exceeds limit of 31", fifoCount)
+	}
+	if byteCount != 2+fifoCount*2 || readN != 12+fifoCount*2 {
+		return nil, errFrameFit
+	}
+
+	values := make([]uint16, fifoCount)
+	for i := range values {
+		values[i] = binary.BigEndian.Uint16(c.buf[12+i*2 : 14+i*2])
+	}
+	return values, nil
+}
+
+// Diagnostic does a diagnostics request with a sub-function. The data word in
+// return is either an echo, as with loopback sub-function 0x0000 (“Return Query
+// Data”), or a value such as the counters of sub-functions 0x000B to 0x0012.
+func (c *TCPClient) Diagnostic(subFunc uint16, data uint16) (uint16, error) {
+	binary.BigEndian.PutUint32(c.buf[8:12], uint32(subFunc)<<16|uint32(data))
+	readN, err := c.sendAndReceive(c.buf[:12], diagnostics)
+	if err != nil {
+		return 0, err
+	}
+
+	if readN != 12 {
+		return 0, errFrameFit
+	}
+	if did := binary.BigEndian.Uint16(c.buf[8:10]); did != subFunc {
+		return 0, fmt.Errorf("Modbus diagnostic sub-function %#04x in response does not match the requested %#04x",
+			did, subFunc)
+	}
+	return binary.BigEndian.Uint16(c.buf[10:12]), nil
+}
+
+// Ping checks the device for liveness with a diagnostics loopback, i.e.,
+// sub-function 0x0000 (“Return Query Data”). Any mismatch in the echo resets
+// the connection.
+func (c *TCPClient) Ping() error {
+	query := uint16(atomic.LoadUint64(&c.TxN))
+	echo, err := c.Diagnostic(0, query)
+	if err != nil {
+		return err
+	}
+	if echo != query {
+		return c.fail(errValueMatch)
+	}
+	return nil
+}
+
+// CommEventCounter fetches the status word and the event counter of a serial
+// device, typically through a gateway. The status is 0xFFFF while a previous
+// command is still in progress. The counter increments for each message that
+// completed successfully.
+func (c *TCPClient) CommEventCounter() (status, count uint16, err error) {
+	readN, err := c.sendAndReceive(c.buf[:8], commEventCounter)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if readN != 12 {
+		return 0, 0, errFrameFit
+	}
+	return binary.BigEndian.Uint16(c.buf[8:10]), binary.BigEndian.Uint16(c.buf[10:12]), nil
+}
+
+// CommEventLog is the response of a serial device to function code 0x0C.
+type CommEventLog struct {
+	Status       uint16 // 0xFFFF while a command is in progress
+	EventCount   uint16 // conform CommEventCounter
+	MessageCount uint16 // messages processed since restart or reset
+	// Up to 64 event bytes, with the most recent first.
+	Events []byte
+}
+
+// CommEventLog fetches the status, the counters and the recent events of a
+// serial device, typically through a gateway.
+func (c *TCPClient) CommEventLog() (CommEventLog, error) {
+	readN, err := c.sendAndReceive(c.buf[:8], commEventLog)
+	if err != nil {
+		return CommEventLog{}, err
+	}
+
+	byteN := int(c.buf[8])
+	if byteN < 6 || readN != 9+byteN {
+		return CommEventLog{}, errFrameFit
+	}
+	return CommEventLog{
+		Status:       binary.BigEndian.Uint16(c.buf[9:11]),
+		EventCount:   binary.BigEndian.Uint16(c.buf[11:13]),
+		MessageCount: binary.BigEndian.Uint16(c.buf[13:15]),
+		Events:       append([]byte(nil), c.buf[15:readN]...),
+	}, nil
+}
+
+// ReportServerID fetches the server identification. The content is device
+// specific. It typically has an identifier, followed by a run-indicator status
+// byte (0x00 for off and 0xFF for on), and any additional vendor data.
+func (c *TCPClient) ReportServerID() ([]byte, error) {
+	readN, err := c.sendAndReceive(c.buf[:8], reportServerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if readN != 9+int(c.buf[8]) {
+		return nil, errFrameFit
+	}
+	return append([]byte(nil), c.buf[9:readN]...), nil
+}
+
+// ReadDeviceIdentification fetches identification objects, starting at an
+// object identifier. Code readDevID is 0x01 for basic, 0x02 for regular, and
+// 0x03 for extended identification, or 0x04 for the one object only. Responses
+// with more to follow are continued automatically. The return maps each object
+// identifier to its value, e.g., 0x00 for the vendor name, 0x01 for the product
+// code, and 0x02 for the revision.
+func (c *TCPClient) ReadDeviceIdentification(readDevID byte, objectID byte) (map[byte][]byte, error) {
+	if readDevID < 1 || readDevID > 4 {
+		return nil, fmt.Errorf("Modbus read device ID code %#02x unknown", readDevID)
+	}
+
+	objects := make(map[byte][]byte)
+	for range 256 { // bounded continuation
+		c.buf[8] = 0x0e // MEI type
+		c.buf[9] = readDevID
+		c.buf[10] = objectID
+		readN, err := c.sendAndReceive(c.buf[:11], encapsulated)
+		if err != nil {
+			return nil, err
+		}
+
+		if readN < 14 || c.buf[8] != 0x0e || c.buf[9] != readDevID {
+			return nil, errFrameFit
+		}
+		moreFollows := c.buf[11] == 0xff
+		nextObjectID := c.buf[12]
+		objectN := int(c.buf[13])
+
+		offset := 14
+		for range objectN {
+			if offset+2 > readN {
+				return nil, errFrameFit
+			}
+			id, size := c.buf[offset], int(c.buf[offset+1])
+			offset += 2
+			if offset+size > readN {
+				return nil, errFrameFit
+			}
+			objects[id] = append([]byte(nil), c.buf[offset:offset+size]...)
+			offset += size
+		}
+		if offset != readN {
+			return nil, errFrameFit
+		}
+
+		if !moreFollows || readDevID == 4 {
+			return objects, nil
+		}
+		objectID = nextObjectID
+	}
+	return nil, errors.New("Modbus device identification continues beyond 256 responses")
+}
+
 // WriteReg updates a single register.
-func (c *TCPClient) WriteReg(addr, value uint16) error {
+func (c *TCPClient) WriteReg(addr, value uint16, opts ...CallOption) error {
+	return c.writeSingle(addr, value, writeReg, opts...)
+}
+
+// WriteCoil switches a single coil.
+func (c *TCPClient) WriteCoil(addr uint16, on bool, opts ...CallOption) error {
+	var value uint16 // off
+	if on {
+		value = 0xff00
+	}
+	return c.writeSingle(addr, value, writeCoil, opts...)
+}
+
+// WriteSingle does a single-value update, which gets confirmed with an echo.
+func (c *TCPClient) writeSingle(addr, value uint16, funcCode byte, opts ...CallOption) error {
 	order := uint32(addr)<<16 | uint32(value)
 	binary.BigEndian.PutUint32(c.buf[8:12], order)
-	readN, err := c.sendAndReceive(c.buf[:12], writeReg)
+	readN, err := c.sendAndReceive(c.buf[:12], funcCode, opts...)
 	if err != nil {
 		return err
 	}
@@ -351,6 +1484,10 @@ func (c *TCPClient) WriteRegs(startAddr uint16, values ...uint16) error {
 	if len(values) > 123 {
 		return ErrLimit
 	}
+	err := checkAddrRange(startAddr, len(values))
+	if err != nil {
+		return err
+	}
 
 	order := uint32(startAddr)<<16 | uint32(len(values))
 	binary.BigEndian.PutUint32(c.buf[8:12], order)
@@ -375,3 +1512,509 @@ func (c *TCPClient) WriteRegs(startAddr uint16, values ...uint16) error {
 	}
 	return nil
 }
+
+// WriteRegsBroadcast updates consecutive registers at a start address on all
+// devices, with broadcast unit identifier 0x00. Broadcasts get no response,
+// i.e., the outcome remains unknown. The return is ErrLimit when more than 123
+// values are given.
+func (c *TCPClient) WriteRegsBroadcast(startAddr uint16, values ...uint16) error {
+	if len(values) == 0 {
+		return nil // allow
+	}
+	if len(values) > 123 {
+		return ErrLimit
+	}
+	err := checkAddrRange(startAddr, len(values))
+	if err != nil {
+		return err
+	}
+
+	binary.BigEndian.PutUint32(c.buf[8:12], uint32(startAddr)<<16|uint32(len(values)))
+	c.buf[12] = byte(len(values) * 2)
+	for i := range values {
+		binary.BigEndian.PutUint16(c.buf[13+(2*i):15+(2*i)], values[i])
+	}
+	return c.broadcast(c.buf[:13+(2*len(values))], writeRegs)
+}
+
+// WriteCoilBroadcast switches a single coil on all devices, with broadcast unit
+// identifier 0x00. Broadcasts get no response, i.e., the outcome remains
+// unknown.
+func (c *TCPClient) WriteCoilBroadcast(addr uint16, on bool) error {
+	var value uint16 // off
+	if on {
+		value = 0xff00
+	}
+	binary.BigEndian.PutUint32(c.buf[8:12], uint32(addr)<<16|uint32(value))
+	return c.broadcast(c.buf[:12], writeCoil)
+}
+
+// Broadcast is sendAndReceive with unit identifier 0, yet without any response
+// reception. BeforeTx, the Interceptors and all other options apply as usual.
+func (c *TCPClient) broadcast(req []byte, funcCode byte) error {
+	c.broadcasting = true
+	defer func() {
+		c.broadcasting = false
+	}()
+	_, err := c.sendAndReceive(req, funcCode)
+	return err
+}
+
+// TxUnitID returns the unit identifier for the frame header.
+func (c *TCPClient) txUnitID() byte {
+	if c.broadcasting {
+		return 0
+	}
+	return c.UnitID
+}
+
+// ReadWriteRegs updates consecutive registers at a write address, and then it
+// fetches consecutive holding-registers at a read address into a read buffer,
+// all in a single transaction. The device does the write before the read. The
+// return is ErrLimit when readBuf is not in range [1, 125], or when the number
+// of write values is not in range [1, 121].
+func (c *TCPClient) ReadWriteRegs(readBuf []uint16, readAddr uint16, writeAddr uint16, writeValues ...uint16) error {
+	if len(readBuf) < 1 || len(readBuf) > 125 || len(writeValues) < 1 || len(writeValues) > 121 {
+		return ErrLimit
+	}
+	err := checkAddrRange(readAddr, len(readBuf))
+	if err != nil {
+		return err
+	}
+	err = checkAddrRange(writeAddr, len(writeValues))
+	if err != nil {
+		return err
+	}
+
+	// compose request
+	binary.BigEndian.PutUint32(c.buf[8:12], uint32(readAddr)<<16|uint32(len(readBuf)))
+	binary.BigEndian.PutUint32(c.buf[12:16], uint32(writeAddr)<<16|uint32(len(writeValues)))
+	c.buf[16] = byte(len(writeValues) * 2)
+	for i := range writeValues {
+		binary.BigEndian.PutUint16(c.buf[17+(2*i):19+(2*i)], writeValues[i])
+	}
+
+	readN, err := c.sendAndReceive(c.buf[:17+(2*len(writeValues))], readWriteRegs)
+	if err != nil {
+		return err
+	}
+
+	if int(c.buf[8]) != len(readBuf)*2 || readN != 9+len(readBuf)*2 {
+		return errFrameFit
+	}
+	for i := range readBuf {
+		readBuf[i] = binary.BigEndian.Uint16(c.buf[9+i*2 : 11+i*2])
+	}
+	return nil
+}
+
+// FileRecordReq is a sub-request for ReadFileRecords.
+type FileRecordReq struct {
+	FileNum   uint16
+	RecordNum uint16
+	Len       uint16 // register count
+}
+
+// ReadFileRecords fetches registers from file records in a single transaction.
+// The return has the registers for each sub-request in order. The return is
+// ErrLimit when either the request or the response exceeds the PDU limit.
+func (c *TCPClient) ReadFileRecords(reqs []FileRecordReq) ([][]uint16, error) {
+	if len(reqs) == 0 {
+		return nil, nil // allowed
+	}
+	if 2+7*len(reqs) > MaxPDUSize {
+		return nil, ErrLimit
+	}
+	resLen := 2 // function code and byte count
+	for _, r := range reqs {
+		resLen += 2 + 2*int(r.Len)
+	}
+	if resLen > MaxPDUSize {
+		return nil, ErrLimit
+	}
+
+	// compose request
+	c.buf[8] = byte(7 * len(reqs))
+	for i, r := range reqs {
+		sub := c.buf[9+7*i : 16+7*i]
+		sub[0] = 6 // reference type
+		binary.BigEndian.PutUint16(sub[1:3], r.FileNum)
+		binary.BigEndian.PutUint16(sub[3:5], r.RecordNum)
+		binary.BigEndian.PutUint16(sub[5:7], r.Len)
+	}
+
+	readN, err := c.sendAndReceive(c.buf[:9+7*len(reqs)], readFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if readN != 7+resLen || int(c.buf[8]) != resLen-2 {
+		return nil, errFrameFit
+	}
+	records := make([][]uint16, len(reqs))
+	offset := 9
+	for i, r := range reqs {
+		if int(c.buf[offset]) != 1+2*int(r.Len) || c.buf[offset+1] != 6 {
+			return nil, fmt.Errorf("Modbus file-record sub-response %d does not match the request", i)
+		}
+		offset += 2
+
+		records[i] = make([]uint16, r.Len)
+		for j := range records[i] {
+			records[i][j] = binary.BigEndian.Uint16(c.buf[offset : offset+2])
+			offset += 2
+		}
+	}
+	return records, nil
+}
+
+// WriteFileRecord updates consecutive registers of a record in a file. The
+// return is ErrLimit when more than 122 values are given.
+func (c *TCPClient) WriteFileRecord(fileNum uint16, recordNum uint16, values []uint16) error {
+	if len(values) == 0 {
+		return nil // allow
+	}
+	if len(values) > 122 {
+		return ErrLimit
+	}
+
+	// compose request with a single sub-request
+	c.buf[8] = byte(7 + 2*len(values))
+	c.buf[9] = 6 // reference type
+	binary.BigEndian.PutUint16(c.buf[10:12], fileNum)
+	binary.BigEndian.PutUint16(c.buf[12:14], recordNum)
+	binary.BigEndian.PutUint16(c.buf[14:16], uint16(len(values)))
+	for i := range values {
+		binary.BigEndian.PutUint16(c.buf[16+(2*i):18+(2*i)], values[i])
+	}
+	reqLen := 16 + (2 * len(values))
+	var req [len(c.buf)]byte
+	copy(req[:], c.buf[8:reqLen])
+
+	readN, err := c.sendAndReceive(c.buf[:reqLen], writeFile)
+	if err != nil {
+		return err
+	}
+
+	// response is an echo of the request
+	if readN != reqLen {
+		return errFrameFit
+	}
+	if string(c.buf[8:reqLen]) != string(req[:reqLen-8]) {
+		return errors.New("Modbus file-record response does not match the request")
+	}
+	return nil
+}
+
+// WriteRegsVerify is like WriteRegs, yet it confirms the outcome with a read-
+// back of the entire range after VerifyDelay. Mismatches get an error which
+// wraps ErrVerify, with the first address that differs, and its offset in
+// values. Devices may clamp or ignore values without an exception.
+func (c *TCPClient) WriteRegsVerify(startAddr uint16, values ...uint16) error {
+	err := c.WriteRegs(startAddr, values...)
+	if err != nil || len(values) == 0 {
+		return err
+	}
+
+	if c.VerifyDelay > 0 {
+		time.Sleep(c.VerifyDelay)
+	}
+
+	p, err := c.readNRegSlice(len(values), startAddr, readHoldRegs)
+	if err != nil {
+		return err
+	}
+	for i, want := range values {
+		got := binary.BigEndian.Uint16(p[2*i:])
+		if got != want {
+			return fmt.Errorf("%w: register %d (offset %d) has %#04x, want %#04x",
+				ErrVerify, startAddr+uint16(i), i, got, want)
+		}
+	}
+	return nil
+}
+
+// WriteRegsIfChanged is like WriteRegs, yet it reads the range first, and it
+// omits the write when all registers have the values already. Any difference
+// causes a write of the entire range.
+func (c *TCPClient) WriteRegsIfChanged(startAddr uint16, values ...uint16) (wrote bool, err error) {
+	if len(values) == 0 {
+		return false, nil // allow
+	}
+	if len(values) > 123 {
+		return false, ErrLimit
+	}
+
+	p, err := c.readNRegSlice(len(values), startAddr, readHoldRegs)
+	if err != nil {
+		return false, err
+	}
+	for i, want := range values {
+		if binary.BigEndian.Uint16(p[2*i:]) != want {
+			return true, c.WriteRegs(startAddr, values...)
+		}
+	}
+	return false, nil
+}
+
+// WriteCoilVerify switches a coil, and then it confirms the outcome with a
+// discrete input, which is typically wired to the physical contact of a relay.
+// The input is read after VerifyDelay. The return is ErrVerify on mismatch.
+func (c *TCPClient) WriteCoilVerify(coilAddr uint16, on bool, inputAddr uint16) error {
+	err := c.WriteCoil(coilAddr, on)
+	if err != nil {
+		return err
+	}
+
+	if c.VerifyDelay > 0 {
+		time.Sleep(c.VerifyDelay)
+	}
+
+	bits, err := c.readNBits(1, inputAddr, readDiscreteInputs)
+	if err != nil {
+		return err
+	}
+	if (bits[0]&1 != 0) != on {
+		return ErrVerify
+	}
+	return nil
+}
+
+// ReadCoil fetches a coil at the given address.
+func (c *TCPClient) ReadCoil(addr uint16, opts ...CallOption) (bool, error) {
+	var buf [1]bool
+	err := c.readBools(buf[:], addr, readCoils, opts...)
+	return buf[0], err
+}
+
+// ReadCoils fetches consecutive coils at a start address into a read buffer.
+// The return is ErrLimit when buf is larger than 2000 entries.
+func (c *TCPClient) ReadCoils(buf []bool, startAddr uint16, opts ...CallOption) error {
+	return c.readBools(buf, startAddr, readCoils, opts...)
+}
+
+// ReadDiscreteInputs fetches consecutive discrete inputs at a start address
+// into a read buffer. The return is ErrLimit when buf is larger than 2000
+// entries.
+func (c *TCPClient) ReadDiscreteInputs(buf []bool, startAddr uint16, opts ...CallOption) error {
+	return c.readBools(buf, startAddr, readDiscreteInputs, opts...)
+}
+
+// ReadDiscreteInputsAligned fetches n consecutive discrete inputs at a start
+// address. Packed has 8 inputs per byte, least significant bit first, aligned
+// to addresses which are a multiple of 8, i.e., bit 0 of the first byte is for
+// address startAddr &^ 7. Bits outside of the range read are zero. The return
+// is ErrLimit when n is not in range 1–2000.
+func (c *TCPClient) ReadDiscreteInputsAligned(startAddr uint16, n int) (bits []bool, packed []byte, err error) {
+	if n < 1 || n > 2000 {
+		return nil, nil, ErrLimit
+	}
+	bits = make([]bool, n)
+	err = c.ReadDiscreteInputs(bits, startAddr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	shift := int(startAddr % 8)
+	packed = make([]byte, (shift+n+7)/8)
+	for i, on := range bits {
+		if on {
+			packed[(shift+i)/8] |= 1 << ((shift + i) % 8)
+		}
+	}
+	return bits, packed, nil
+}
+
+// ReadCoilsPacked fetches count consecutive coils at a start address into dst,
+// with eight coils per byte, least significant bit first, conform the protocol.
+// Padding in the last byte is zero. The return is ErrLimit when count exceeds
+// 2000, and io.ErrShortBuffer when dst has less than (count + 7) / 8 bytes.
+func (c *TCPClient) ReadCoilsPacked(dst []byte, count int, startAddr uint16) error {
+	if count <= 0 {
+		return nil // allowed
+	}
+	if count > 2000 {
+		return ErrLimit
+	}
+	if len(dst) < (count+7)/8 {
+		return io.ErrShortBuffer
+	}
+
+	bits, err := c.readNBits(count, startAddr, readCoils)
+	if err != nil {
+		return err
+	}
+	n := copy(dst, bits)
+	if count%8 != 0 {
+		dst[n-1] &= 1<<(count%8) - 1
+	}
+	return nil
+}
+
+func (c *TCPClient) readBools(buf []bool, startAddr uint16, funcCode byte, opts ...CallOption) error {
+	if len(buf) == 0 {
+		return nil // allowed
+	}
+	if len(buf) > 2000 {
+		return ErrLimit
+	}
+
+	bits, err := c.readNBits(len(buf), startAddr, funcCode, opts...)
+	if err != nil {
+		return err
+	}
+
+	// padding in the last byte is ignored
+	for i := range buf {
+		buf[i] = bits[i/8]&(1<<(i%8)) != 0
+	}
+	return nil
+}
+
+// ReadCoilsBig fetches count consecutive coils at a start address. Bit i of
+// the return is coil startAddr + i. Counts over 2000 are split into multiple
+// transactions. The return is ErrAddrRange when the range exceeds address
+// 0xFFFF.
+func (c *TCPClient) ReadCoilsBig(startAddr, count uint16) (*big.Int, error) {
+	err := checkAddrRange(startAddr, int(count))
+	if err != nil {
+		return nil, err
+	}
+
+	z := new(big.Int)
+	for offset := 0; offset < int(count); offset += 2000 {
+		n := min(int(count)-offset, 2000)
+		bits, err := c.readNBits(n, startAddr+uint16(offset), readCoils)
+		if err != nil {
+			return nil, err
+		}
+		for i := range n {
+			if bits[i/8]&(1<<(i%8)) != 0 {
+				z.SetBit(z, offset+i, 1)
+			}
+		}
+	}
+	return z, nil
+}
+
+// CoilRun is a range of consecutive coils which are set.
+type CoilRun struct {
+	Addr uint16 // first coil
+	N    int    // coil count
+}
+
+// CoilSummary has population statistics of a coil range.
+type CoilSummary struct {
+	Set         int       // number of coils set
+	First, Last int       // address of the first and last coil set, or -1
+	Runs        []CoilRun // in ascending order
+}
+
+// SummarizeCoils fetches count consecutive coils at a start address conform
+// ReadCoilsBig, and it returns population statistics.
+func (c *TCPClient) SummarizeCoils(startAddr, count uint16) (CoilSummary, error) {
+	z, err := c.ReadCoilsBig(startAddr, count)
+	if err != nil {
+		return CoilSummary{}, err
+	}
+
+	s := CoilSummary{First: -1, Last: -1}
+	for i := range z.BitLen() {
+		if z.Bit(i) == 0 {
+			continue
+		}
+		addr := int(startAddr) + i
+
+		s.Set++
+		if s.First < 0 {
+			s.First = addr
+		}
+		if s.Last >= 0 && s.Last == addr-1 {
+			s.Runs[len(s.Runs)-1].N++
+		} else {
+			s.Runs = append(s.Runs, CoilRun{Addr: uint16(addr), N: 1})
+		}
+		s.Last = addr
+	}
+	return s, nil
+}
+
+// ReadNBits fetches n consecutive coils or discrete inputs at a start address.
+// The slice in return has 8 bits per byte, with the least significant bit
+// first. Bytes stop being valid at the next invocation to the TCPClient.
+func (c *TCPClient) readNBits(n int, startAddr uint16, funcCode byte, opts ...CallOption) ([]byte, error) {
+	err := checkAddrRange(startAddr, n)
+	if err != nil {
+		return nil, err
+	}
+
+	// compose request
+	binary.BigEndian.PutUint32(c.buf[8:12], uint32(startAddr)<<16|uint32(n))
+
+	readN, err := c.sendAndReceive(c.buf[:12], funcCode, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	byteN := (n + 7) / 8
+	if int(c.buf[8]) != byteN || readN != 9+byteN {
+		return nil, errFrameFit
+	}
+	return c.buf[9 : 9+byteN], nil
+}
+
+// WriteRegBit sets or clears a single bit in a holding register, with bit 0 as
+// the least significant. The update is atomic with a mask write. Devices which
+// lack support for mask writes get a read–modify–write instead, which is prone
+// to race conditions with other masters. The return is ErrBitIndex when bit is
+// over 15.
+func (c *TCPClient) WriteRegBit(addr uint16, bit uint, on bool) error {
+	if bit > 15 {
+		return ErrBitIndex
+	}
+	andMask := ^uint16(1 << bit)
+	var orMask uint16
+	if on {
+		orMask = 1 << bit
+	}
+
+	err := c.MaskWriteReg(addr, andMask, orMask)
+	if !errors.Is(err, ErrFunc) {
+		return err
+	}
+
+	// fallback
+	v, err := c.ReadHoldReg(addr)
+	if err != nil {
+		return err
+	}
+	return c.WriteReg(addr, v&andMask|orMask)
+}
+
+// MaskWriteReg updates a holding register to (current AND andMask) OR (orMask
+// AND (NOT andMask)). The device does the read–modify–write atomically.
+func (c *TCPClient) MaskWriteReg(addr, andMask, orMask uint16) error {
+	binary.BigEndian.PutUint16(c.buf[8:10], addr)
+	binary.BigEndian.PutUint16(c.buf[10:12], andMask)
+	binary.BigEndian.PutUint16(c.buf[12:14], orMask)
+	readN, err := c.sendAndReceive(c.buf[:14], maskWriteReg)
+	if err != nil {
+		return err
+	}
+
+	if readN != 14 {
+		return errFrameFit
+	}
+	if binary.BigEndian.Uint16(c.buf[8:10]) != addr {
+		return errAddrMatch
+	}
+	if did := binary.BigEndian.Uint16(c.buf[10:12]); did != andMask {
+		return fmt.Errorf("Modbus AND mask %#04x in response does not match the requested %#04x",
+			did, andMask)
+	}
+	if did := binary.BigEndian.Uint16(c.buf[12:14]); did != orMask {
+		return fmt.Errorf("Modbus OR mask %#04x in response does not match the requested %#04x",
+			did, orMask)
+	}
+	return nil
+}
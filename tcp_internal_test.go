@@ -0,0 +1,1909 @@
+package modbus
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWriteCoilVerify(t *testing.T) {
+	tests := []struct {
+		name    string
+		on      bool
+		input   bool // discrete input state
+		wantErr error
+	}{
+		{"on", true, true, nil},
+		{"off", false, false, nil},
+		{"stuck off", true, false, ErrVerify},
+		{"stuck on", false, true, ErrVerify},
+	}
+	for _, test := range tests {
+		var reqs [][]byte
+		c := TCPClient{
+			Conn: &fakeDevice{respond: func(req []byte) []byte {
+				reqs = append(reqs, req[7:])
+				if req[7] == readDiscreteInputs {
+					if test.input {
+						return responseFrame(req, 1, 1)
+					}
+					return responseFrame(req, 1, 0)
+				}
+				return responseFrame(req, req[8:]...) // echo
+			}},
+			UnitID:      1,
+			VerifyDelay: time.Millisecond,
+		}
+
+		err := c.WriteCoilVerify(3, test.on, 9)
+		if !errors.Is(err, test.wantErr) {
+			t.Errorf("%s: got error %v, want %v", test.name, err, test.wantErr)
+		}
+
+		coilValue := byte(0x00)
+		if test.on {
+			coilValue = 0xff
+		}
+		want := [][]byte{
+			{writeCoil, 0, 3, coilValue, 0},
+			{readDiscreteInputs, 0, 9, 0, 1},
+		}
+		if !reflect.DeepEqual(reqs, want) {
+			t.Errorf("%s: got requests %#x, want %#x", test.name, reqs, want)
+		}
+	}
+}
+
+func TestBroadcastRead(t *testing.T) {
+	tests := []struct {
+		name    string
+		allow   bool
+		read    func(c *TCPClient) error
+		wantErr error
+	}{
+		{"holding register", false, func(c *TCPClient) error {
+			_, err := c.ReadHoldReg(1)
+			return err
+		}, ErrBroadcastRead},
+		{"input registers", false, func(c *TCPClient) error {
+			return c.ReadInputRegs(make([]uint16, 2), 1)
+		}, ErrBroadcastRead},
+		{"allowed", true, func(c *TCPClient) error {
+			_, err := c.ReadHoldReg(1)
+			return err
+		}, nil},
+		{"write", false, func(c *TCPClient) error {
+			return c.WriteReg(1, 42)
+		}, nil},
+	}
+	for _, test := range tests {
+		var reqN int
+		c := TCPClient{
+			Conn: &fakeDevice{respond: func(req []byte) []byte {
+				reqN++
+				if req[7] == writeReg {
+					return responseFrame(req, req[8:]...) // echo
+				}
+				return responseFrame(req, 2, 0, 42)
+			}},
+			UnitID:             0,
+			AllowBroadcastRead: test.allow,
+		}
+
+		err := test.read(&c)
+		if !errors.Is(err, test.wantErr) {
+			t.Errorf("%s: got error %v, want %v", test.name, err, test.wantErr)
+		}
+		if test.wantErr != nil && reqN != 0 {
+			t.Errorf("%s: got %d requests submitted, want none", test.name, reqN)
+		}
+	}
+}
+
+func TestReadHoldRegAny(t *testing.T) {
+	tests := []struct {
+		name       string
+		exceptions map[uint16]Exception
+		addrs      []uint16
+		want       uint16
+		wantErr    error
+	}{
+		{"first", nil, []uint16{1, 2}, 101, nil},
+		{"fallback on ErrAddr", map[uint16]Exception{1: ErrAddr}, []uint16{1, 2}, 102, nil},
+		{"fallback on ErrFunc", map[uint16]Exception{1: ErrFunc, 2: ErrAddr}, []uint16{1, 2, 3}, 103, nil},
+		{"abort on ErrDev", map[uint16]Exception{1: ErrDev}, []uint16{1, 2}, 0, ErrDev},
+		{"none", map[uint16]Exception{1: ErrAddr, 2: ErrFunc}, []uint16{1, 2}, 0, ErrFunc},
+		{"no addresses", nil, nil, 0, ErrAddr},
+	}
+	for _, test := range tests {
+		c := TCPClient{
+			Conn: &fakeDevice{respond: func(req []byte) []byte {
+				addr := binary.BigEndian.Uint16(req[8:10])
+				if e, ok := test.exceptions[addr]; ok {
+					frame := responseFrame(req, byte(e))
+					frame[7] |= errorFlag
+					return frame
+				}
+				return responseFrame(req, 2, 0, byte(100+addr))
+			}},
+			UnitID: 1,
+		}
+
+		got, err := c.ReadHoldRegAny(test.addrs...)
+		if !errors.Is(err, test.wantErr) {
+			t.Errorf("%s: got error %v, want %v", test.name, err, test.wantErr)
+		}
+		if got != test.want {
+			t.Errorf("%s: got %d, want %d", test.name, got, test.want)
+		}
+	}
+}
+
+func TestLastTransaction(t *testing.T) {
+	tests := []struct {
+		name  string
+		chunk int
+	}{
+		{"single packet", 0},
+		{"fragmented", 9},
+	}
+	for _, test := range tests {
+		c := TCPClient{
+			Conn: &fakeDevice{respond: func(req []byte) []byte {
+				return responseFrame(req, 4, 0, 1, 0, 2)
+			}, chunk: test.chunk},
+			UnitID: 1,
+		}
+		if got := c.LastTransaction(); !reflect.DeepEqual(got, TxDetail{}) {
+			t.Errorf("%s: got %+v before use, want zero", test.name, got)
+		}
+
+		var buf [2]uint16
+		if err := c.ReadHoldRegs(buf[:], 7); err != nil {
+			t.Fatalf("%s: read error: %s", test.name, err)
+		}
+		got := c.LastTransaction()
+		if got.TxID != uint16(c.TxN) || got.FuncCode != readHoldRegs || got.Fragmented != (test.chunk != 0) || got.Duration < 0 {
+			t.Errorf("%s: got %+v", test.name, got)
+		}
+		if want := []byte{readHoldRegs, 0, 7, 0, 2}; !bytes.Equal(got.Request, want) {
+			t.Errorf("%s: got request %#x, want %#x", test.name, got.Request, want)
+		}
+		if want := []byte{readHoldRegs, 4, 0, 1, 0, 2}; !bytes.Equal(got.Response, want) {
+			t.Errorf("%s: got response %#x, want %#x", test.name, got.Response, want)
+		}
+
+		// copies not affected by subsequent use
+		if _, err := c.ReadHoldReg(9); err == nil {
+			t.Fatalf("%s: register count mismatch accepted", test.name)
+		}
+		if got.Request[2] != 7 || got.Response[1] != 4 {
+			t.Errorf("%s: detail changed by subsequent transaction", test.name)
+		}
+		if next := c.LastTransaction(); next.Request[2] != 9 {
+			t.Errorf("%s: got request %#x after read of address 9", test.name, next.Request)
+		}
+	}
+}
+
+func TestReadCoilsBig(t *testing.T) {
+	on := []uint16{100, 101, 107, 2099, 2100, 2599}
+	c := TCPClient{
+		Conn: &fakeDevice{respond: func(req []byte) []byte {
+			start := int(binary.BigEndian.Uint16(req[8:10]))
+			n := int(binary.BigEndian.Uint16(req[10:12]))
+			data := make([]byte, 1+(n+7)/8)
+			data[0] = byte(len(data) - 1)
+			for _, addr := range on {
+				if i := int(addr) - start; i >= 0 && i < n {
+					data[1+i/8] |= 1 << (i % 8)
+				}
+			}
+			return responseFrame(req, data...)
+		}},
+		UnitID: 1,
+	}
+
+	tests := []struct {
+		start, count uint16
+		wantTxN      uint64
+	}{
+		{100, 0, 0},
+		{100, 8, 1},
+		{101, 2000, 1},
+		{100, 2500, 2},
+	}
+	for _, test := range tests {
+		txN := c.TxN
+		got, err := c.ReadCoilsBig(test.start, test.count)
+		if err != nil {
+			t.Errorf("%d coils at %d: got error %s", test.count, test.start, err)
+			continue
+		}
+		want := new(big.Int)
+		for _, addr := range on {
+			if addr >= test.start && int(addr) < int(test.start)+int(test.count) {
+				want.SetBit(want, int(addr-test.start), 1)
+			}
+		}
+		if got.Cmp(want) != 0 {
+			t.Errorf("%d coils at %d: got %#x, want %#x", test.count, test.start, got, want)
+		}
+		if n := c.TxN - txN; n != test.wantTxN {
+			t.Errorf("%d coils at %d: got %d transactions, want %d", test.count, test.start, n, test.wantTxN)
+		}
+	}
+
+	if _, err := c.ReadCoilsBig(0xfff0, 0x20); !errors.Is(err, ErrAddrRange) {
+		t.Errorf("got error %v for range beyond 0xFFFF, want ErrAddrRange", err)
+	}
+}
+
+func TestOnFrag(t *testing.T) {
+	tests := []struct {
+		chunk int
+		want  [][2]int // frame size and read count per event
+	}{
+		{0, nil},
+		{100, nil},
+		{9, [][2]int{{13, 9}}},
+		{5, [][2]int{{13, 10}}}, // two chunks for the first 9 bytes
+	}
+	for _, test := range tests {
+		var got [][2]int
+		c := TCPClient{
+			Conn: &fakeDevice{respond: func(req []byte) []byte {
+				return responseFrame(req, 4, 0, 1, 0, 2)
+			}, chunk: test.chunk},
+			UnitID: 1,
+			OnFrag: func(frameLen, readN int) {
+				got = append(got, [2]int{frameLen, readN})
+			},
+		}
+
+		var buf [2]uint16
+		if err := c.ReadHoldRegs(buf[:], 7); err != nil {
+			t.Fatalf("chunk %d: read error: %s", test.chunk, err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("chunk %d: got events %d, want %d", test.chunk, got, test.want)
+		}
+		if c.FragN != uint64(len(test.want)) {
+			t.Errorf("chunk %d: got FragN %d, want %d", test.chunk, c.FragN, len(test.want))
+		}
+	}
+}
+
+func TestMalformedResponses(t *testing.T) {
+	tests := []struct {
+		name    string
+		respond func(req []byte) []byte
+		// trailing bytes go unnoticed when in a separate packet
+		unchunked bool
+	}{
+		{"byte count", func(req []byte) []byte {
+			return responseFrame(req, 3, 0, 1, 0, 2)
+		}, false},
+		{"register count", func(req []byte) []byte {
+			return responseFrame(req, 2, 0, 1)
+		}, false},
+		{"transaction identifier", func(req []byte) []byte {
+			frame := responseFrame(req, 4, 0, 1, 0, 2)
+			frame[1]++
+			return frame
+		}, false},
+		{"unit identifier", func(req []byte) []byte {
+			frame := responseFrame(req, 4, 0, 1, 0, 2)
+			frame[6]++
+			return frame
+		}, false},
+		{"function code", func(req []byte) []byte {
+			frame := responseFrame(req, 4, 0, 1, 0, 2)
+			frame[7] = writeReg
+			return frame
+		}, false},
+		{"oversized length", func(req []byte) []byte {
+			frame := responseFrame(req, 4, 0, 1, 0, 2)
+			binary.BigEndian.PutUint16(frame[4:6], 0xffff)
+			return frame
+		}, false},
+		{"truncated fragment", func(req []byte) []byte {
+			frame := responseFrame(req, 4, 0, 1, 0, 2)
+			return frame[:len(frame)-1]
+		}, false},
+		{"trailing bytes", func(req []byte) []byte {
+			return append(responseFrame(req, 4, 0, 1, 0, 2), 0)
+		}, true},
+		{"exception size", func(req []byte) []byte {
+			frame := responseFrame(req, byte(ErrBusy), 0)
+			frame[7] |= errorFlag
+			return frame
+		}, false},
+	}
+
+	for _, test := range tests {
+		for _, chunk := range []int{0, 1, 9} {
+			if chunk != 0 && test.unchunked {
+				continue
+			}
+			c := TCPClient{
+				Conn:   &fakeDevice{respond: test.respond, chunk: chunk},
+				UnitID: 1,
+			}
+			var buf [2]uint16
+			err := c.ReadHoldRegs(buf[:], 1001)
+			if err == nil {
+				t.Errorf("%s with %d-byte chunks: got no error", test.name, chunk)
+			}
+		}
+	}
+}
+
+func FuzzResponse(f *testing.F) {
+	f.Add([]byte{0, 0, 0, 0, 0, 7, 1, 3, 4, 0, 1, 0, 2}, uint8(0))
+	f.Add([]byte{0, 0, 0, 0, 0, 3, 1, 0x83, 2}, uint8(3))
+	f.Add([]byte{0, 0, 0, 0, 0xff, 0xff, 1, 3, 4}, uint8(1))
+
+	f.Fuzz(func(t *testing.T, frame []byte, chunk uint8) {
+		respond := func(req []byte) []byte {
+			if len(frame) >= 2 {
+				// pass the transaction identifier check
+				copy(frame[:2], req[:2])
+			}
+			return frame
+		}
+		c := TCPClient{
+			Conn:   &fakeDevice{respond: respond, chunk: int(chunk)},
+			UnitID: 1,
+		}
+
+		var buf [2]uint16
+		err := c.ReadHoldRegs(buf[:], 1001)
+		if err != nil {
+			return
+		}
+		// trailing bytes go unnoticed when in a separate packet
+		if len(frame) < 13 || len(frame) > 13 && (chunk == 0 || int(chunk) >= len(frame)) {
+			t.Errorf("accepted %d-byte frame for 2 registers", len(frame))
+		}
+	})
+}
+
+func TestWriteRegBit(t *testing.T) {
+	tests := []struct {
+		name    string
+		noMask  bool // mask write unsupported
+		bit     uint
+		on      bool
+		want    uint16
+		wantTxN uint64
+		wantErr error
+	}{
+		{"set", false, 1, true, 0b1010_0011, 1, nil},
+		{"clear", false, 7, false, 0b0010_0001, 1, nil},
+		{"set fallback", true, 15, true, 0b1000_0000_1010_0001, 3, nil},
+		{"clear fallback", true, 0, false, 0b1010_0000, 3, nil},
+		{"bit index", false, 16, true, 0b1010_0001, 0, ErrBitIndex},
+	}
+	for _, test := range tests {
+		reg := uint16(0b1010_0001)
+		c := TCPClient{
+			Conn: &fakeDevice{respond: func(req []byte) []byte {
+				switch req[7] {
+				case readHoldRegs:
+					return responseFrame(req, 2, byte(reg>>8), byte(reg))
+				case writeReg:
+					reg = binary.BigEndian.Uint16(req[10:12])
+				case maskWriteReg:
+					if test.noMask {
+						frame := responseFrame(req, byte(ErrFunc))
+						frame[7] |= errorFlag
+						return frame
+					}
+					andMask := binary.BigEndian.Uint16(req[10:12])
+					orMask := binary.BigEndian.Uint16(req[12:14])
+					reg = reg&andMask | orMask&^andMask
+				}
+				return responseFrame(req, req[8:]...)
+			}},
+			UnitID: 1,
+		}
+
+		err := c.WriteRegBit(4, test.bit, test.on)
+		if !errors.Is(err, test.wantErr) {
+			t.Errorf("%s: got error %v, want %v", test.name, err, test.wantErr)
+		}
+		if reg != test.want {
+			t.Errorf("%s: got register %#b, want %#b", test.name, reg, test.want)
+		}
+		if c.TxN != test.wantTxN {
+			t.Errorf("%s: got %d transactions, want %d", test.name, c.TxN, test.wantTxN)
+		}
+	}
+}
+
+func TestTolerateFuncCodeMismatch(t *testing.T) {
+	dev := &fakeDevice{respond: func(req []byte) []byte {
+		frame := responseFrame(req, 2, 0, 42)
+		frame[7] = readHoldRegs
+		return frame
+	}}
+	c := TCPClient{Conn: dev, UnitID: 1}
+	if _, err := c.ReadInputReg(7); err == nil {
+		t.Fatal("function code mismatch accepted")
+	}
+
+	c.Conn = dev // reset by failure
+	c.TolerateFuncCodeMismatch = true
+	var logBuf bytes.Buffer
+	c.Logger = slog.New(slog.NewTextHandler(&logBuf, nil))
+	got, err := c.ReadInputReg(7)
+	if err != nil {
+		t.Fatal("tolerated function code mismatch:", err)
+	}
+	if got != 42 {
+		t.Errorf("got %d, want 42", got)
+	}
+	if !bytes.Contains(logBuf.Bytes(), []byte(`msg="tolerated function code mismatch" request=4 response=3`)) {
+		t.Errorf("got log %q, want warning on tolerated function code", logBuf.String())
+	}
+}
+
+func TestSummarizeCoils(t *testing.T) {
+	tests := []struct {
+		name  string
+		on    []uint16
+		start uint16
+		count uint16
+		want  CoilSummary
+	}{
+		{"none", nil, 10, 20, CoilSummary{First: -1, Last: -1}},
+		{"single", []uint16{15}, 10, 20, CoilSummary{
+			Set: 1, First: 15, Last: 15,
+			Runs: []CoilRun{{Addr: 15, N: 1}},
+		}},
+		{"runs", []uint16{10, 11, 12, 20, 22, 23}, 10, 20, CoilSummary{
+			Set: 6, First: 10, Last: 23,
+			Runs: []CoilRun{{Addr: 10, N: 3}, {Addr: 20, N: 1}, {Addr: 22, N: 2}},
+		}},
+		{"out of range", []uint16{9, 30}, 10, 20, CoilSummary{First: -1, Last: -1}},
+		{"split read", []uint16{2008, 2009, 2010}, 10, 2500, CoilSummary{
+			Set: 3, First: 2008, Last: 2010,
+			Runs: []CoilRun{{Addr: 2008, N: 3}},
+		}},
+	}
+	for _, test := range tests {
+		c := TCPClient{
+			Conn: &fakeDevice{respond: func(req []byte) []byte {
+				start := int(binary.BigEndian.Uint16(req[8:10]))
+				n := int(binary.BigEndian.Uint16(req[10:12]))
+				data := make([]byte, 1+(n+7)/8)
+				data[0] = byte(len(data) - 1)
+				for _, addr := range test.on {
+					if i := int(addr) - start; i >= 0 && i < n {
+						data[1+i/8] |= 1 << (i % 8)
+					}
+				}
+				return responseFrame(req, data...)
+			}},
+			UnitID: 1,
+		}
+
+		got, err := c.SummarizeCoils(test.start, test.count)
+		if err != nil {
+			t.Errorf("%s: got error: %s", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+// DeadlineDevice records each deadline set.
+type deadlineDevice struct {
+	fakeDevice
+	deadlines []time.Time
+}
+
+func (d *deadlineDevice) SetDeadline(t time.Time) error {
+	d.deadlines = append(d.deadlines, t)
+	return nil
+}
+
+func TestSetTxDeadline(t *testing.T) {
+	fixed := time.Now().Add(time.Hour)
+	tests := []struct {
+		name      string
+		txTimeout time.Duration
+		adaptive  bool
+		deadline  time.Time
+		want      time.Duration // from now, with zero for fixed
+	}{
+		{"none", 0, false, time.Time{}, -1},
+		{"TxTimeout", time.Minute, false, time.Time{}, time.Minute},
+		{"deadline", 0, false, fixed, 0},
+		{"deadline over TxTimeout", time.Minute, false, fixed, 0},
+		{"deadline over AdaptiveTimeout", time.Minute, true, fixed, 0},
+	}
+	for _, test := range tests {
+		dev := &deadlineDevice{fakeDevice: fakeDevice{respond: func(req []byte) []byte {
+			return responseFrame(req, 2, 0, 42)
+		}}}
+		c := TCPClient{Conn: dev, UnitID: 1, TxTimeout: test.txTimeout}
+		if test.adaptive {
+			c.AdaptiveTimeout = new(AdaptiveTimeout)
+			c.AdaptiveTimeout.Observe(time.Second)
+		}
+		c.SetTxDeadline(test.deadline)
+
+		start := time.Now()
+		if _, err := c.ReadHoldReg(1); err != nil {
+			t.Fatalf("%s: read error: %s", test.name, err)
+		}
+
+		switch {
+		case test.want < 0:
+			if len(dev.deadlines) != 0 {
+				t.Errorf("%s: got deadlines %v, want none", test.name, dev.deadlines)
+			}
+			continue
+		case len(dev.deadlines) != 2 || !dev.deadlines[1].IsZero():
+			t.Errorf("%s: got deadlines %v, want one plus a reset", test.name, dev.deadlines)
+			continue
+		}
+		got := dev.deadlines[0]
+		if test.want == 0 {
+			if !got.Equal(test.deadline) {
+				t.Errorf("%s: got deadline %s, want %s", test.name, got, test.deadline)
+			}
+		} else if got.Before(start.Add(test.want)) || got.After(time.Now().Add(test.want)) {
+			t.Errorf("%s: got deadline %s, want %s from %s", test.name, got, test.want, start)
+		}
+	}
+}
+
+func TestNoReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	var dialN atomic.Int32
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			dialN.Add(1)
+			go func() {
+				defer conn.Close()
+				req := make([]byte, 12)
+				for {
+					if _, err := io.ReadFull(conn, req); err != nil {
+						return
+					}
+					conn.Write(responseFrame(req, 2, 0, 42))
+				}
+			}()
+		}
+	}()
+
+	tests := []struct {
+		name        string
+		noReconnect bool
+		wantErr     error // after failure
+	}{
+		{"reconnect", false, nil},
+		{"no reconnect", true, ErrNoConn},
+	}
+	for _, test := range tests {
+		dialN.Store(0)
+		c := TCPClient{
+			Conn: &fakeDevice{respond: func(req []byte) []byte {
+				return req[:7] // malformed
+			}},
+			RemoteAddr:  ln.Addr().String(),
+			UnitID:      1,
+			NoReconnect: test.noReconnect,
+		}
+
+		if _, err := c.ReadHoldReg(1); err == nil {
+			t.Fatalf("%s: no error for malformed response", test.name)
+		}
+		_, err := c.ReadHoldReg(1)
+		if !errors.Is(err, test.wantErr) {
+			t.Errorf("%s: got error %v after failure, want %v", test.name, err, test.wantErr)
+		}
+		if test.wantErr == nil {
+			c.Close()
+			continue
+		}
+		if n := dialN.Load(); n != 0 {
+			t.Errorf("%s: got %d dials before Connect, want 0", test.name, n)
+		}
+
+		if err := c.Connect(); err != nil {
+			t.Fatalf("%s: reconnect error: %s", test.name, err)
+		}
+		if got, err := c.ReadHoldReg(1); err != nil || got != 42 {
+			t.Errorf("%s: got (%d, %v) after Connect, want (42, nil)", test.name, got, err)
+		}
+		c.Close()
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	c := TCPClient{
+		Conn: &fakeDevice{respond: func(req []byte) []byte {
+			return nil // timeout
+		}},
+		UnitID:     1,
+		BreakAfter: 2,
+		BreakFor:   time.Hour,
+	}
+	for i := range 2 {
+		_, err := c.ReadHoldReg(1)
+		if err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("call %d got error %v, want connection failure", i+1, err)
+		}
+	}
+	_, err := c.ReadHoldReg(1)
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("got error %v after 2 failures, want ErrCircuitOpen", err)
+	}
+	var reqErr *RequestError
+	if !errors.As(err, &reqErr) || reqErr.FuncCode != readHoldRegs || reqErr.Addr != 1 {
+		t.Errorf("got error %#v, want RequestError with request details", err)
+	}
+	if got := c.CircuitState(); got != CircuitOpen {
+		t.Errorf("got state %s after 2 failures, want open", got)
+	}
+
+	// half-open probe
+	c.openUntil = time.Now()
+	if got := c.CircuitState(); got != CircuitHalfOpen {
+		t.Errorf("got state %s after BreakFor, want half-open", got)
+	}
+	c.Conn = &fakeDevice{respond: func(req []byte) []byte {
+		return responseFrame(req, 2, 0, 42)
+	}}
+	if _, err := c.ReadHoldReg(1); err != nil {
+		t.Fatal("probe error:", err)
+	}
+	if _, err := c.ReadHoldReg(1); err != nil {
+		t.Error("closed circuit error:", err)
+	}
+	if got := c.CircuitState(); got != CircuitClosed {
+		t.Errorf("got state %s after probe, want closed", got)
+	}
+}
+
+// Only dial and I/O failures count for the circuit breaker.
+func TestCircuitBreakerDenials(t *testing.T) {
+	c := TCPClient{
+		RemoteAddr:       "localhost:0", // refused
+		BreakAfter:       2,
+		BreakFor:         time.Hour,
+		ReconnectBackoff: ReconnectBackoff{Initial: time.Hour},
+	}
+	if _, err := c.ReadHoldReg(1); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("got error %v for refused dial, want connection failure", err)
+	}
+
+	denials := []struct {
+		name string
+		f    func() error
+	}{
+		{"broadcast read", func() error {
+			_, err := c.ReadHoldReg(1)
+			return err
+		}},
+		{"reconnect backoff", func() error {
+			c.UnitID = 1
+			_, err := c.ReadHoldReg(1)
+			return err
+		}},
+		{"canceled context", func() error {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			c.redialAt = time.Time{}
+			return c.WithContext(ctx, func() error {
+				_, err := c.ReadHoldReg(1)
+				return err
+			})
+		}},
+		{"no reconnect", func() error {
+			c.NoReconnect = true
+			c.failed = true
+			_, err := c.ReadHoldReg(1)
+			return err
+		}},
+	}
+	for _, d := range denials {
+		if err := d.f(); err == nil {
+			t.Fatalf("%s: no error", d.name)
+		}
+		if got := c.CircuitState(); got != CircuitClosed {
+			t.Errorf("%s: got state %s after one dial failure, want closed", d.name, got)
+		}
+	}
+}
+
+func TestReadDiscreteInputsAligned(t *testing.T) {
+	c := TCPClient{
+		Conn: &fakeDevice{respond: func(req []byte) []byte {
+			// padding bits in last byte set
+			return responseFrame(req, 2, 0xe5, 0xff)
+		}},
+		UnitID: 1,
+	}
+	bits, packed, err := c.ReadDiscreteInputsAligned(13, 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantBits := []bool{true, false, true, false, false, true, true, true, true}
+	if !reflect.DeepEqual(bits, wantBits) {
+		t.Errorf("got bits %t, want %t", bits, wantBits)
+	}
+	// inputs 8–12 and 22–23 not read
+	wantPacked := []byte{0xa0, 0x3c}
+	if !bytes.Equal(packed, wantPacked) {
+		t.Errorf("got packed %#x, want %#x", packed, wantPacked)
+	}
+
+	for _, n := range []int{-1, 0, 2001} {
+		_, _, err := c.ReadDiscreteInputsAligned(13, n)
+		if err != ErrLimit {
+			t.Errorf("got error %v for %d inputs, want ErrLimit", err, n)
+		}
+	}
+	if got := c.TxN; got != 1 {
+		t.Errorf("got %d transactions, want 1", got)
+	}
+}
+
+func TestBeforeTx(t *testing.T) {
+	errCustom := errors.New("test gate")
+	tests := []struct {
+		name    string
+		gate    error
+		wantErr error
+	}{
+		{"open", nil, nil},
+		{"paused", ErrPaused, ErrPaused},
+		{"custom", errCustom, errCustom},
+	}
+	for _, test := range tests {
+		var reqN, gateN int
+		c := TCPClient{
+			Conn: &fakeDevice{respond: func(req []byte) []byte {
+				reqN++
+				return responseFrame(req, 2, 0, 42)
+			}},
+			UnitID:     1,
+			BreakAfter: 1,
+			BeforeTx: func() error {
+				gateN++
+				return test.gate
+			},
+		}
+
+		for range 2 {
+			_, err := c.ReadHoldReg(1)
+			// propagates as is
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("%s: got error %v, want %v", test.name, err, test.wantErr)
+			}
+		}
+		if gateN != 2 {
+			t.Errorf("%s: gate invoked %d times, want 2", test.name, gateN)
+		}
+		if test.wantErr != nil && (reqN != 0 || c.TxN != 0) {
+			t.Errorf("%s: got %d requests submitted and TxN %d, want none", test.name, reqN, c.TxN)
+		}
+	}
+}
+
+func TestReadCoils(t *testing.T) {
+	dev := &fakeDevice{respond: func(req []byte) []byte {
+		if req[7] != readCoils || !bytes.Equal(req[8:12], []byte{0, 100, 0, 10}) {
+			t.Errorf("got request PDU %#x", req[7:])
+		}
+		return responseFrame(req, 2, 0x81, 0xfe)
+	}}
+	c := TCPClient{Conn: dev, UnitID: 1}
+
+	var buf [10]bool
+	err := c.ReadCoils(buf[:], 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [10]bool{true, false, false, false, false, false, false, true, false, true}
+	if buf != want {
+		t.Errorf("got %t, want %t", buf, want)
+	}
+
+	dev.respond = func(req []byte) []byte {
+		return responseFrame(req, 1, 0x81)
+	}
+	if err := c.ReadCoils(buf[:], 100); err != errFrameFit {
+		t.Errorf("got error %v for byte count mismatch, want errFrameFit", err)
+	}
+
+	if err := c.ReadCoils(make([]bool, 2001), 0); !errors.Is(err, ErrLimit) {
+		t.Errorf("got error %v for 2001 coils, want ErrLimit", err)
+	}
+
+	dev.respond = func(req []byte) []byte {
+		if !bytes.Equal(req[7:12], []byte{readCoils, 0, 9, 0, 1}) {
+			t.Errorf("got request PDU %#x", req[7:])
+		}
+		return responseFrame(req, 1, 0x01)
+	}
+	if on, err := c.ReadCoil(9); err != nil || !on {
+		t.Errorf("got (%t, %v) for single coil, want (true, nil)", on, err)
+	}
+
+	dev.respond = func(req []byte) []byte {
+		return responseFrame(req, 2, 0x81, 0xfe)
+	}
+	var packed [2]byte
+	if err := c.ReadCoilsPacked(packed[:], 10, 100); err != nil {
+		t.Fatal(err)
+	}
+	if packed != [2]byte{0x81, 0x02} {
+		t.Errorf("got packed coils %#x, want 0x8102", packed)
+	}
+	if err := c.ReadCoilsPacked(packed[:1], 10, 100); err != io.ErrShortBuffer {
+		t.Errorf("got error %v for 1-byte buffer, want io.ErrShortBuffer", err)
+	}
+}
+
+func TestMaskWriteReg(t *testing.T) {
+	var got []byte
+	var resp func(req []byte) []byte
+	c := TCPClient{
+		Conn: &fakeDevice{respond: func(req []byte) []byte {
+			got = req[7:]
+			return resp(req)
+		}},
+		UnitID: 1,
+	}
+
+	// example from the specification
+	resp = func(req []byte) []byte { return responseFrame(req, req[8:]...) }
+	if err := c.MaskWriteReg(4, 0x00f2, 0x0025); err != nil {
+		t.Fatal("mask write error:", err)
+	}
+	if want := []byte{maskWriteReg, 0, 4, 0, 0xf2, 0, 0x25}; !bytes.Equal(got, want) {
+		t.Errorf("got request %#x, want %#x", got, want)
+	}
+
+	resp = func(req []byte) []byte { return responseFrame(req, 0, 4, 0, 0xf2, 0, 0x24) }
+	if err := c.MaskWriteReg(4, 0x00f2, 0x0025); err == nil {
+		t.Error("mismatched echo accepted")
+	}
+
+	resp = func(req []byte) []byte {
+		frame := responseFrame(req, byte(ErrFunc))
+		frame[7] |= errorFlag
+		return frame
+	}
+	if err := c.MaskWriteReg(4, 0, 0); !errors.Is(err, ErrFunc) {
+		t.Errorf("got error %v, want ErrFunc", err)
+	}
+}
+
+func TestReadWriteRegs(t *testing.T) {
+	var got []byte
+	c := TCPClient{
+		Conn: &fakeDevice{respond: func(req []byte) []byte {
+			got = req[7:]
+			n := int(binary.BigEndian.Uint16(req[10:12]))
+			data := []byte{byte(2 * n)}
+			for i := range n {
+				data = binary.BigEndian.AppendUint16(data, uint16(100+i))
+			}
+			return responseFrame(req, data...)
+		}},
+		UnitID: 1,
+	}
+
+	buf := make([]uint16, 3)
+	if err := c.ReadWriteRegs(buf, 19, 20, 7, 8); err != nil {
+		t.Fatal("read/write error:", err)
+	}
+	if want := []byte{readWriteRegs, 0, 19, 0, 3, 0, 20, 0, 2, 4, 0, 7, 0, 8}; !bytes.Equal(got, want) {
+		t.Errorf("got request %#x, want %#x", got, want)
+	}
+	if want := []uint16{100, 101, 102}; !reflect.DeepEqual(buf, want) {
+		t.Errorf("got registers %d, want %d", buf, want)
+	}
+
+	tests := []struct {
+		name   string
+		readN  int
+		values []uint16
+	}{
+		{"no read", 0, []uint16{7}},
+		{"read limit", 126, []uint16{7}},
+		{"no write", 1, nil},
+		{"write limit", 1, make([]uint16, 122)},
+	}
+	for _, test := range tests {
+		err := c.ReadWriteRegs(make([]uint16, test.readN), 10, 20, test.values...)
+		if !errors.Is(err, ErrLimit) {
+			t.Errorf("%s: got error %v, want ErrLimit", test.name, err)
+		}
+	}
+	if c.TxN != 1 {
+		t.Errorf("got %d transactions, want 1", c.TxN)
+	}
+}
+
+func TestReadFIFOQueue(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte // response PDU data
+		want    []uint16
+		wantErr bool
+	}{
+		{"empty", []byte{0, 2, 0, 0}, []uint16{}, false},
+		// example from the specification
+		{"two", []byte{0, 6, 0, 2, 0x01, 0xb8, 0x12, 0x84}, []uint16{0x01b8, 0x1284}, false},
+		{"byte count", []byte{0, 4, 0, 2, 0x01, 0xb8, 0x12, 0x84}, nil, true},
+		{"FIFO count", []byte{0, 6, 0, 3, 0x01, 0xb8, 0x12, 0x84}, nil, true},
+		{"over 31", append([]byte{0, 66, 0, 32}, make([]byte, 64)...), nil, true},
+		{"short", []byte{0, 2, 0}, nil, true},
+	}
+	for _, test := range tests {
+		c := TCPClient{
+			Conn: &fakeDevice{respond: func(req []byte) []byte {
+				if want := []byte{0x04, 0xde}; !bytes.Equal(req[8:], want) {
+					t.Errorf("%s: got request data %#x, want %#x", test.name, req[8:], want)
+				}
+				return responseFrame(req, test.data...)
+			}},
+			UnitID: 1,
+		}
+
+		got, err := c.ReadFIFOQueue(0x04de)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: got %#x, want error", test.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: got error: %s", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %#x, want %#x", test.name, got, test.want)
+		}
+	}
+}
+
+func TestWriteFileRecord(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []uint16
+		respond func(req []byte) []byte
+		wantReq []byte // nil for no request
+		wantErr bool
+	}{
+		{"none", nil, nil, nil, false},
+		// example from the specification
+		{"echo", []uint16{0x06af, 0x04be, 0x100d}, func(req []byte) []byte {
+			return responseFrame(req, req[8:]...)
+		}, []byte{0x0d, 0x06, 0x00, 0x04, 0x00, 0x07, 0x00, 0x03, 0x06, 0xaf, 0x04, 0xbe, 0x10, 0x0d}, false},
+		{"echo mismatch", []uint16{0x06af}, func(req []byte) []byte {
+			res := responseFrame(req, req[8:]...)
+			res[len(res)-1]++
+			return res
+		}, []byte{0x09, 0x06, 0x00, 0x04, 0x00, 0x07, 0x00, 0x01, 0x06, 0xaf}, true},
+	}
+	for _, test := range tests {
+		var gotReq []byte
+		c := TCPClient{
+			Conn: &fakeDevice{respond: func(req []byte) []byte {
+				gotReq = req[8:]
+				return test.respond(req)
+			}},
+			UnitID: 1,
+		}
+
+		err := c.WriteFileRecord(4, 7, test.values)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: got error %v, want error %t", test.name, err, test.wantErr)
+		}
+		if !bytes.Equal(gotReq, test.wantReq) {
+			t.Errorf("%s: got request data %#x, want %#x", test.name, gotReq, test.wantReq)
+		}
+	}
+
+	var c TCPClient // no connection needed
+	if err := c.WriteFileRecord(4, 7, make([]uint16, 123)); !errors.Is(err, ErrLimit) {
+		t.Errorf("got error %v for 123 values, want ErrLimit", err)
+	}
+}
+
+func TestReadFileRecords(t *testing.T) {
+	c := TCPClient{
+		Conn: &fakeDevice{respond: func(req []byte) []byte {
+			want := []byte{14, 6, 0, 4, 0, 1, 0, 2, 6, 0, 3, 0, 9, 0, 1}
+			if !bytes.Equal(req[8:], want) {
+				t.Errorf("got request data %#x, want %#x", req[8:], want)
+			}
+			return responseFrame(req, 10,
+				5, 6, 0x0d, 0xfe, 0x00, 0x20,
+				3, 6, 0x33, 0xcd)
+		}},
+		UnitID: 1,
+	}
+
+	got, err := c.ReadFileRecords([]FileRecordReq{
+		{FileNum: 4, RecordNum: 1, Len: 2},
+		{FileNum: 3, RecordNum: 9, Len: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]uint16{{0x0dfe, 0x0020}, {0x33cd}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#x, want %#x", got, want)
+	}
+}
+
+func TestReportServerID(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte // response PDU data
+		want    []byte
+		wantErr bool
+	}{
+		{"run indicator on", []byte{4, 'A', 'B', 'C', 0xff}, []byte{'A', 'B', 'C', 0xff}, false},
+		{"empty", []byte{0}, []byte{}, false},
+		{"byte count", []byte{5, 'A', 'B', 'C', 0xff}, nil, true},
+	}
+	for _, test := range tests {
+		c := TCPClient{
+			Conn: &fakeDevice{respond: func(req []byte) []byte {
+				if len(req) != 8 || req[7] != reportServerID {
+					t.Errorf("%s: got request %#x, want function code 0x11 only", test.name, req)
+				}
+				return responseFrame(req, test.data...)
+			}},
+			UnitID: 1,
+		}
+
+		got, err := c.ReportServerID()
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: got error %v, want error %t", test.name, err, test.wantErr)
+		}
+		if !bytes.Equal(got, test.want) {
+			t.Errorf("%s: got %#x, want %#x", test.name, got, test.want)
+		}
+
+		// copy not affected by subsequent use
+		if err == nil && len(got) != 0 {
+			c.ReportServerID()
+			c.buf[9]++
+			if got[0] != test.want[0] {
+				t.Errorf("%s: return shares the buffer", test.name)
+			}
+		}
+	}
+}
+
+func TestReadDeviceIdentification(t *testing.T) {
+	c := TCPClient{
+		Conn: &fakeDevice{respond: func(req []byte) []byte {
+			switch req[10] {
+			case 0:
+				return responseFrame(req, 0x0e, 1, 1, 0xff, 2, 2,
+					0, 3, 'A', 'C', 'M',
+					1, 2, 'X', '1')
+			case 2:
+				return responseFrame(req, 0x0e, 1, 1, 0, 0, 1,
+					2, 3, 'v', '1', '0')
+			}
+			t.Errorf("got request for object %#02x", req[10])
+			return nil
+		}},
+		UnitID: 1,
+	}
+
+	got, err := c.ReadDeviceIdentification(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[byte][]byte{0: []byte("ACM"), 1: []byte("X1"), 2: []byte("v10")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestCommEventCounter(t *testing.T) {
+	dev := &fakeDevice{respond: func(req []byte) []byte {
+		if !bytes.Equal(req[7:], []byte{commEventCounter}) {
+			t.Errorf("got request PDU %#x", req[7:])
+		}
+		return responseFrame(req, 0xff, 0xff, 0x01, 0x08)
+	}}
+	c := TCPClient{Conn: dev, UnitID: 1}
+
+	status, count, err := c.CommEventCounter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != 0xffff || count != 0x0108 {
+		t.Errorf("got status %#04x and count %#04x, want 0xffff and 0x0108", status, count)
+	}
+
+	dev.respond = func(req []byte) []byte {
+		return responseFrame(req, 0, 0, 1)
+	}
+	if _, _, err := c.CommEventCounter(); !errors.Is(err, errFrameFit) {
+		t.Errorf("got error %v for short response, want errFrameFit", err)
+	}
+}
+
+func TestCommEventLog(t *testing.T) {
+	dev := &fakeDevice{respond: func(req []byte) []byte {
+		if !bytes.Equal(req[7:], []byte{commEventLog}) {
+			t.Errorf("got request PDU %#x", req[7:])
+		}
+		return responseFrame(req, 8, 0, 0, 0x01, 0x08, 0x01, 0x21, 0x20, 0x00)
+	}}
+	c := TCPClient{Conn: dev, UnitID: 1}
+
+	got, err := c.CommEventLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := CommEventLog{
+		EventCount:   0x0108,
+		MessageCount: 0x0121,
+		Events:       []byte{0x20, 0x00},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	dev.respond = func(req []byte) []byte {
+		return responseFrame(req, 8, 0, 0, 0x01, 0x08, 0x01, 0x21, 0x20)
+	}
+	if _, err := c.CommEventLog(); !errors.Is(err, errFrameFit) {
+		t.Errorf("got error %v for byte count mismatch, want errFrameFit", err)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	var reqs [][]byte
+	c := TCPClient{
+		Conn: &fakeDevice{respond: func(req []byte) []byte {
+			reqs = append(reqs, req)
+			if len(reqs) < 3 {
+				frame := responseFrame(req, byte(ErrBusy))
+				frame[7] |= errorFlag
+				return frame
+			}
+			return responseFrame(req, 2, 0, 42)
+		}},
+		UnitID: 1,
+		Retry:  RetryPolicy{MaxAttempts: 3},
+	}
+
+	got, err := c.ReadHoldReg(1001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 42 {
+		t.Errorf("got %d, want 42", got)
+	}
+	if c.RetryN != 2 {
+		t.Errorf("got RetryN %d, want 2", c.RetryN)
+	}
+	for i, req := range reqs {
+		if !bytes.Equal(req[7:], reqs[0][7:]) {
+			t.Errorf("attempt %d got PDU %#x, want %#x", i+1, req[7:], reqs[0][7:])
+		}
+	}
+
+	// writes need opt-in
+	reqs = nil
+	if err := c.WriteReg(1001, 7); !errors.Is(err, ErrBusy) {
+		t.Errorf("got write error %v, want ErrBusy", err)
+	}
+
+	stats := c.Stats()
+	if stats.TxN != 4 || stats.RetryN != 2 || stats.FragN != 0 {
+		t.Errorf("got stats %+v, want TxN 4 and RetryN 2", stats)
+	}
+	if stats.LastRTT <= 0 || stats.AvgRTT <= 0 || stats.MaxRTT < stats.LastRTT || stats.MaxRTT < stats.AvgRTT {
+		t.Errorf("got round-trip times %+v", stats)
+	}
+	c.ResetStats()
+	if got := c.Stats(); got != (Stats{}) {
+		t.Errorf("got stats %+v after reset, want zero", got)
+	}
+}
+
+func TestCallOptions(t *testing.T) {
+	// device is busy on every other request
+	var reqN int
+	c := TCPClient{
+		Conn: &fakeDevice{respond: func(req []byte) []byte {
+			reqN++
+			if reqN%2 == 1 {
+				frame := responseFrame(req, byte(ErrBusy))
+				frame[7] |= errorFlag
+				return frame
+			}
+			if req[7] == readHoldRegs {
+				return responseFrame(req, 2, 0, 42)
+			}
+			return responseFrame(req, req[8:12]...)
+		}},
+		UnitID: 1,
+		Retry:  RetryPolicy{MaxAttempts: 3},
+	}
+
+	tests := []struct {
+		name string
+		opts []CallOption
+		want error
+	}{
+		{"default", nil, ErrBusy},
+		{"retries", []CallOption{WithRetries(2)}, nil},
+		{"no retries", []CallOption{WithRetries(1)}, ErrBusy},
+		{"policy reads only", []CallOption{WithRetryPolicy(RetryPolicy{MaxAttempts: 5})}, ErrBusy},
+		{"policy with writes", []CallOption{WithRetryPolicy(RetryPolicy{MaxAttempts: 2, Writes: true})}, nil},
+	}
+	for _, test := range tests {
+		reqN = 0
+		err := c.WriteReg(1001, 7, test.opts...)
+		if !errors.Is(err, test.want) {
+			t.Errorf("%s: got error %v, want %v", test.name, err, test.want)
+		}
+	}
+	if c.Retry != (RetryPolicy{MaxAttempts: 3}) {
+		t.Errorf("got Retry %+v after calls with options, want client default", c.Retry)
+	}
+
+	// option wins over the client default for reads too
+	reqN = 0
+	if _, err := c.ReadHoldReg(1001, WithRetries(1)); !errors.Is(err, ErrBusy) {
+		t.Errorf("got read error %v without retries, want ErrBusy", err)
+	}
+	reqN = 0
+	if got, err := c.ReadHoldReg(1001); err != nil || got != 42 {
+		t.Errorf("got read (%d, %v) with client default, want (42, nil)", got, err)
+	}
+}
+
+func TestTxHooks(t *testing.T) {
+	var events []string
+	c := TCPClient{
+		Conn: &fakeDevice{respond: func(req []byte) []byte {
+			if req[9] == 2 {
+				frame := responseFrame(req, byte(ErrAddr))
+				frame[7] |= errorFlag
+				return frame
+			}
+			return responseFrame(req, 2, 0, 42)
+		}},
+		UnitID: 1,
+		OnTxStart: func(funcCode byte, addr uint16) {
+			events = append(events, fmt.Sprintf("start %#02x@%d", funcCode, addr))
+		},
+		OnTxEnd: func(funcCode byte, addr uint16, reqN, resN int, err error) {
+			events = append(events, fmt.Sprintf("end %#02x@%d %d/%d %v", funcCode, addr, reqN, resN, err))
+		},
+	}
+
+	if _, err := c.ReadHoldReg(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ReadHoldReg(2); !errors.Is(err, ErrAddr) {
+		t.Fatalf("got error %v, want ErrAddr", err)
+	}
+	want := []string{
+		"start 0x03@1",
+		"end 0x03@1 12/11 <nil>",
+		"start 0x03@2",
+		"end 0x03@2 12/9 " + ErrAddr.Error(),
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("got events %q, want %q", events, want)
+	}
+}
+
+func TestWriteRegsVerify(t *testing.T) {
+	// device clamps values to 1000
+	var regs [8]uint16
+	c := TCPClient{
+		Conn: &fakeDevice{respond: func(req []byte) []byte {
+			addr := binary.BigEndian.Uint16(req[8:10])
+			n := binary.BigEndian.Uint16(req[10:12])
+			switch req[7] {
+			case writeRegs:
+				for i := range n {
+					regs[addr+i] = min(binary.BigEndian.Uint16(req[13+2*i:]), 1000)
+				}
+				return responseFrame(req, req[8:12]...)
+			case readHoldRegs:
+				data := []byte{byte(2 * n)}
+				for _, r := range regs[addr : addr+n] {
+					data = binary.BigEndian.AppendUint16(data, r)
+				}
+				return responseFrame(req, data...)
+			}
+			t.Errorf("got request PDU %#x", req[7:])
+			return nil
+		}},
+		UnitID: 1,
+	}
+
+	if err := c.WriteRegsVerify(2, 7, 999, 1000); err != nil {
+		t.Fatal(err)
+	}
+	err := c.WriteRegsVerify(2, 7, 999, 1001)
+	if !errors.Is(err, ErrVerify) {
+		t.Fatalf("got error %v for clamped value, want ErrVerify", err)
+	}
+	const want = "Modbus read-back does not match the value written: register 4 (offset 2) has 0x03e8, want 0x03e9"
+	if err.Error() != want {
+		t.Errorf("got error %q, want %q", err, want)
+	}
+}
+
+func TestReadHoldRegsLarge(t *testing.T) {
+	// registers hold their address, with an exception at 400
+	c := TCPClient{
+		Conn: &fakeDevice{respond: func(req []byte) []byte {
+			addr := binary.BigEndian.Uint16(req[8:10])
+			n := binary.BigEndian.Uint16(req[10:12])
+			if n > 125 {
+				t.Errorf("got request for %d registers", n)
+			}
+			if addr <= 400 && addr+n > 400 {
+				frame := responseFrame(req, byte(ErrAddr))
+				frame[7] |= errorFlag
+				return frame
+			}
+			frame := responseFrame(req, byte(2*n))
+			for i := range n {
+				frame = binary.BigEndian.AppendUint16(frame, addr+i)
+			}
+			binary.BigEndian.PutUint16(frame[4:6], uint16(len(frame)-6))
+			return frame
+		}},
+		UnitID: 1,
+	}
+
+	buf := make([]uint16, 300)
+	n, err := c.ReadHoldRegsLarge(buf, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(buf) {
+		t.Errorf("got n %d, want %d", n, len(buf))
+	}
+	for i, v := range buf {
+		if v != uint16(10+i) {
+			t.Fatalf("got register %d value %d, want %d", 10+i, v, 10+i)
+		}
+	}
+
+	n, err = c.ReadHoldRegsLarge(buf, 200)
+	if !errors.Is(err, ErrAddr) || n != 125 {
+		t.Errorf("got (%d, %v) over exception, want (125, ErrAddr)", n, err)
+	}
+
+	if _, err := c.ReadHoldRegsLarge(buf, 0xffff); !errors.Is(err, ErrAddrRange) {
+		t.Errorf("got error %v for address overflow, want ErrAddrRange", err)
+	}
+}
+
+func TestDetectMaxRegs(t *testing.T) {
+	var probeN int
+	c := TCPClient{
+		Conn: &fakeDevice{respond: func(req []byte) []byte {
+			probeN++
+			n := int(binary.BigEndian.Uint16(req[10:12]))
+			if n > 42 {
+				frame := responseFrame(req, byte(ErrValue))
+				frame[7] |= errorFlag
+				return frame
+			}
+			return responseFrame(req, append([]byte{byte(2 * n)}, make([]byte, 2*n)...)...)
+		}},
+		UnitID: 1,
+	}
+
+	got, err := c.DetectMaxRegs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 42 {
+		t.Errorf("got %d registers, want 42", got)
+	}
+	if probeN > 7 {
+		t.Errorf("took %d probes, want 7 at most", probeN)
+	}
+}
+
+func TestRawPDU(t *testing.T) {
+	c := TCPClient{
+		Conn: &fakeDevice{respond: func(req []byte) []byte {
+			if req[7] != 0x41 || !bytes.Equal(req[8:], []byte{1, 2, 3}) {
+				t.Errorf("got request PDU %#x", req[7:])
+			}
+			return responseFrame(req, 4, 5)
+		}},
+		UnitID: 1,
+	}
+
+	got, err := c.RawPDU(0x41, []byte{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, []byte{4, 5}) {
+		t.Errorf("got response data %#x, want 0x0405", got)
+	}
+
+	if _, err := c.RawPDU(0x41, make([]byte, 253)); err != ErrLimit {
+		t.Errorf("got error %v for 254-byte PDU, want ErrLimit", err)
+	}
+	if _, err := c.RawPDU(0x41|errorFlag, nil); err == nil {
+		t.Error("no error for function code with error flag")
+	}
+}
+
+func TestPing(t *testing.T) {
+	dev := &fakeDevice{respond: func(req []byte) []byte {
+		if req[7] != diagnostics || req[8] != 0 || req[9] != 0 {
+			t.Errorf("got request PDU %#x", req[7:])
+		}
+		return responseFrame(req, req[8:12]...)
+	}}
+	c := TCPClient{Conn: dev, UnitID: 1}
+	if err := c.Ping(); err != nil {
+		t.Error("ping error:", err)
+	}
+
+	dev.respond = func(req []byte) []byte {
+		return responseFrame(req, 0, 0, ^req[10], req[11])
+	}
+	if err := c.Ping(); err != errValueMatch {
+		t.Errorf("got error %v for echo mismatch, want errValueMatch", err)
+	}
+	if c.Conn != nil {
+		t.Error("connection retained after echo mismatch")
+	}
+}
+
+func TestResync(t *testing.T) {
+	dev := &fakeDevice{chunk: 5, respond: func(req []byte) []byte {
+		return responseFrame(req, 2, 0x12, 0x34)
+	}}
+	// late response from a previous transaction
+	dev.pending = []byte{0, 9, 0, 0, 0, 5, 1, 3, 2, 0xff, 0xff}
+	c := TCPClient{Conn: dev, UnitID: 1}
+
+	if err := c.Resync(); err != nil {
+		t.Fatal("resync error:", err)
+	}
+	if len(dev.pending) != 0 {
+		t.Errorf("%d bytes pending after resync", len(dev.pending))
+	}
+	got, err := c.ReadHoldReg(7)
+	if err != nil {
+		t.Fatal("read error after resync:", err)
+	}
+	if got != 0x1234 {
+		t.Errorf("got register value %#x, want 0x1234", got)
+	}
+}
+
+func TestWriteBroadcast(t *testing.T) {
+	var reqs [][]byte
+	c := TCPClient{
+		Conn: &fakeDevice{respond: func(req []byte) []byte {
+			reqs = append(reqs, req)
+			return nil // no response to broadcasts
+		}},
+		UnitID: 1,
+	}
+
+	if err := c.WriteRegsBroadcast(7, 0x1234, 0x5678); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WriteCoilBroadcast(9, true); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]byte{
+		{0, 1, 0, 0, 0, 11, 0, writeRegs, 0, 7, 0, 2, 4, 0x12, 0x34, 0x56, 0x78},
+		{0, 2, 0, 0, 0, 6, 0, writeCoil, 0, 9, 0xff, 0},
+	}
+	if !reflect.DeepEqual(reqs, want) {
+		t.Errorf("got requests %#x, want %#x", reqs, want)
+	}
+	if c.TxN != 2 {
+		t.Errorf("got TxN %d, want 2", c.TxN)
+	}
+
+	// broadcasts pass the same gates as other transactions
+	reqs = nil
+	c.BeforeTx = func() error { return ErrPaused }
+	if err := c.WriteRegsBroadcast(7, 1); err != ErrPaused {
+		t.Errorf("got error %v from paused client, want ErrPaused", err)
+	}
+	if err := c.WriteCoilBroadcast(9, false); err != ErrPaused {
+		t.Errorf("got error %v from paused client, want ErrPaused", err)
+	}
+	if len(reqs) != 0 {
+		t.Errorf("paused client wrote %#x", reqs)
+	}
+
+	c.BeforeTx = nil
+	var intercepted []byte
+	c.Interceptors = []Interceptor{func(next TxFunc) TxFunc {
+		return func(funcCode byte, req []byte) ([]byte, error) {
+			intercepted = append(intercepted, funcCode)
+			return next(funcCode, req)
+		}
+	}}
+	var started []byte
+	c.OnTxStart = func(funcCode byte, addr uint16) {
+		started = append(started, funcCode)
+	}
+	if err := c.WriteCoilBroadcast(9, false); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(intercepted, []byte{writeCoil}) || !bytes.Equal(started, []byte{writeCoil}) {
+		t.Errorf("got intercepted %#x and started %#x, want the write coil function code", intercepted, started)
+	}
+	if len(reqs) != 1 || reqs[0][6] != 0 {
+		t.Errorf("got requests %#x, want one to unit identifier 0", reqs)
+	}
+	if c.UnitID != 1 {
+		t.Errorf("got UnitID %d after broadcast, want 1", c.UnitID)
+	}
+}
+
+func TestWithUnit(t *testing.T) {
+	var units []byte
+	c := TCPClient{
+		Conn: &fakeDevice{respond: func(req []byte) []byte {
+			units = append(units, req[6])
+			return responseFrame(req, 2, 0, req[6])
+		}},
+		UnitID: 1,
+	}
+
+	for _, unit := range []byte{7, 9} {
+		got, err := c.WithUnit(unit).ReadHoldReg(100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != uint16(unit) {
+			t.Errorf("got %d from unit %d", got, unit)
+		}
+	}
+	if _, err := c.ReadHoldReg(100); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{7, 9, 1}; !bytes.Equal(units, want) {
+		t.Errorf("got unit identifiers %d, want %d", units, want)
+	}
+}
+
+func TestDialFunc(t *testing.T) {
+	var dialN int
+	c := TCPClient{
+		UnitID: 1,
+		DialFunc: func() (net.Conn, error) {
+			dialN++
+			return &fakeDevice{respond: func(req []byte) []byte {
+				if dialN == 1 {
+					return req[:7] // malformed
+				}
+				return responseFrame(req, 2, 0, 42)
+			}}, nil
+		},
+	}
+
+	if _, err := c.ReadHoldReg(1); err == nil {
+		t.Error("no error for malformed response")
+	}
+	got, err := c.ReadHoldReg(1)
+	if err != nil {
+		t.Fatal("read error after redial:", err)
+	}
+	if got != 42 {
+		t.Errorf("got register value %d, want 42", got)
+	}
+	if dialN != 2 {
+		t.Errorf("got %d dials, want 2", dialN)
+	}
+}
+
+func TestWarmup(t *testing.T) {
+	tests := []struct {
+		name    string
+		warmupN int
+		// warmup response
+		respond func(req []byte) []byte
+		wantN   uint16 // warmup quantity requested
+		wantDNs int
+	}{
+		{"pass", 2, func(req []byte) []byte {
+			return responseFrame(req, 4, 0, 1, 0, 2)
+		}, 2, 1},
+		{"capped", 200, func(req []byte) []byte {
+			return responseFrame(req, append([]byte{250}, make([]byte, 250)...)...)
+		}, 125, 1},
+		{"exception", 1, func(req []byte) []byte {
+			frame := responseFrame(req, byte(ErrAddr))
+			frame[7] |= errorFlag
+			return frame
+		}, 1, 1},
+		{"timeout", 1, func(req []byte) []byte {
+			return nil
+		}, 1, 2},
+	}
+	for _, test := range tests {
+		var dialN int
+		var warmupN uint16
+		c := TCPClient{
+			UnitID:     1,
+			WarmupAddr: 0x100,
+			WarmupN:    test.warmupN,
+			DialFunc: func() (net.Conn, error) {
+				dialN++
+				return &fakeDevice{respond: func(req []byte) []byte {
+					if binary.BigEndian.Uint16(req[8:10]) == 0x100 {
+						warmupN = binary.BigEndian.Uint16(req[10:12])
+						return test.respond(req)
+					}
+					return responseFrame(req, 2, 0, 42)
+				}}, nil
+			},
+		}
+
+		got, err := c.ReadHoldReg(1)
+		if err != nil {
+			t.Errorf("%s: read error: %s", test.name, err)
+			continue
+		}
+		if got != 42 {
+			t.Errorf("%s: got register value %d, want 42", test.name, got)
+		}
+		if warmupN != test.wantN {
+			t.Errorf("%s: got warmup quantity %d, want %d", test.name, warmupN, test.wantN)
+		}
+		if dialN != test.wantDNs {
+			t.Errorf("%s: got %d dials, want %d", test.name, dialN, test.wantDNs)
+		}
+	}
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close() // refuse connections
+
+	c := TCPClient{
+		RemoteAddr:       addr,
+		UnitID:           1,
+		ReconnectBackoff: ReconnectBackoff{Initial: time.Hour},
+	}
+	if _, err := c.ReadHoldReg(1); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("got error %v for refused dial", err)
+	}
+	if _, err := c.ReadHoldReg(1); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("got error %v during backoff, want ErrCircuitOpen", err)
+	}
+
+	b := ReconnectBackoff{Initial: time.Second, Max: 5 * time.Second}
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second} {
+		if got := b.delay(i + 1); got != want {
+			t.Errorf("got delay %s after %d failures, want %s", got, i+1, want)
+		}
+	}
+}
+
+func TestAddrRange(t *testing.T) {
+	c := TCPClient{
+		Conn: &fakeDevice{respond: func(req []byte) []byte {
+			t.Errorf("got request PDU %#x", req[7:])
+			return nil
+		}},
+		UnitID: 1,
+	}
+	tests := map[string]func() error{
+		"ReadHoldRegs": func() error {
+			return c.ReadHoldRegs(make([]uint16, 10), 0xfffb)
+		},
+		"ReadNInputRegSlice": func() error {
+			_, err := c.ReadNInputRegSlice(2, 0xffff)
+			return err
+		},
+		"ReadCoils": func() error {
+			return c.ReadCoils(make([]bool, 2), 0xffff)
+		},
+		"WriteRegs": func() error {
+			return c.WriteRegs(0xffff, 1, 2)
+		},
+		"ReadWriteRegs": func() error {
+			return c.ReadWriteRegs(make([]uint16, 1), 0, 0xffff, 1, 2)
+		},
+		"Client.ReadDiscreteInputs": func() error {
+			return Client{&c}.ReadDiscreteInputs(make([]bool, 2), 0xffff)
+		},
+		"Client.WriteRegs": func() error {
+			return Client{&c}.WriteRegs(0xffff, 1, 2)
+		},
+	}
+	for name, f := range tests {
+		if err := f(); !errors.Is(err, ErrAddrRange) {
+			t.Errorf("%s got error %v, want ErrAddrRange", name, err)
+		}
+	}
+
+	// last address is fine
+	c.Conn.(*fakeDevice).respond = func(req []byte) []byte {
+		return responseFrame(req, 2, 0, 42)
+	}
+	if _, err := c.ReadHoldReg(0xffff); err != nil {
+		t.Error("read of address 0xFFFF:", err)
+	}
+}
+
+func TestInterceptors(t *testing.T) {
+	var reqs [][]byte
+	dev := &fakeDevice{respond: func(req []byte) []byte {
+		reqs = append(reqs, req[7:])
+		return responseFrame(req, 2, 0, 42)
+	}}
+
+	// trace records the order of invocation
+	var trace []string
+	named := func(name string) Interceptor {
+		return func(next TxFunc) TxFunc {
+			return func(funcCode byte, req []byte) ([]byte, error) {
+				trace = append(trace, name+" in")
+				res, err := next(funcCode, req)
+				trace = append(trace, name+" out")
+				return res, err
+			}
+		}
+	}
+	// twice invokes next two times, like a retry or a hedge does
+	twice := func(next TxFunc) TxFunc {
+		return func(funcCode byte, req []byte) ([]byte, error) {
+			if _, err := next(funcCode, req); err != nil {
+				return nil, err
+			}
+			return next(funcCode, req)
+		}
+	}
+	// cached answers without any transaction
+	cached := func(next TxFunc) TxFunc {
+		return func(funcCode byte, req []byte) ([]byte, error) {
+			return []byte{2, 0, 99}, nil
+		}
+	}
+
+	tests := []struct {
+		name         string
+		interceptors []Interceptor
+		want         uint16
+		wantTrace    []string
+		wantReqN     int
+	}{
+		{"order", []Interceptor{named("a"), named("b")}, 42,
+			[]string{"a in", "b in", "b out", "a out"}, 1},
+		{"short-circuit", []Interceptor{named("a"), cached, named("b")}, 99,
+			[]string{"a in", "a out"}, 0},
+		{"next twice", []Interceptor{twice}, 42, nil, 2},
+	}
+	for _, test := range tests {
+		reqs, trace = nil, nil
+		c := TCPClient{Conn: dev, UnitID: 1, Interceptors: test.interceptors}
+
+		got, err := c.ReadHoldReg(1001)
+		if err != nil {
+			t.Errorf("%s: got error %v", test.name, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: got value %d, want %d", test.name, got, test.want)
+		}
+		if !reflect.DeepEqual(trace, test.wantTrace) {
+			t.Errorf("%s: got trace %q, want %q", test.name, trace, test.wantTrace)
+		}
+		if len(reqs) != test.wantReqN {
+			t.Errorf("%s: got %d requests, want %d", test.name, len(reqs), test.wantReqN)
+		}
+		for i, req := range reqs {
+			if want := []byte{readHoldRegs, 0x03, 0xe9, 0, 1}; !bytes.Equal(req, want) {
+				t.Errorf("%s: request %d got PDU %#x, want %#x", test.name, i+1, req, want)
+			}
+		}
+	}
+}
+
+func TestPostWriteDelay(t *testing.T) {
+	const delay = 20 * time.Millisecond
+
+	var last time.Time
+	var gaps []time.Duration
+	c := TCPClient{
+		Conn: &fakeDevice{respond: func(req []byte) []byte {
+			if !last.IsZero() {
+				gaps = append(gaps, time.Since(last))
+			}
+			last = time.Now()
+			switch req[7] {
+			case readWriteRegs, readHoldRegs:
+				return responseFrame(req, 2, 0, 42)
+			}
+			return responseFrame(req, req[8:12]...)
+		}},
+		UnitID:         1,
+		PostWriteDelay: delay,
+	}
+
+	tests := []struct {
+		name  string
+		tx    func() error
+		delay bool // applies to the next transaction
+	}{
+		{"write register", func() error { return c.WriteReg(1, 2) }, true},
+		{"read and write registers", func() error {
+			return c.ReadWriteRegs(make([]uint16, 1), 1, 2, 3)
+		}, true},
+		{"broadcast", func() error { return c.WriteRegsBroadcast(1, 2) }, true},
+		{"read", func() error {
+			_, err := c.ReadHoldReg(1)
+			return err
+		}, false},
+	}
+	for _, test := range tests {
+		last, gaps = time.Time{}, nil
+		if err := test.tx(); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if _, err := c.ReadHoldReg(1); err != nil {
+			t.Fatalf("%s: read after: %v", test.name, err)
+		}
+		if len(gaps) != 1 {
+			t.Fatalf("%s: got %d gaps, want 1", test.name, len(gaps))
+		}
+		if test.delay && gaps[0] < delay {
+			t.Errorf("%s: next transaction after %s, want %s or more", test.name, gaps[0], delay)
+		}
+		if !test.delay && gaps[0] >= delay {
+			t.Errorf("%s: next transaction after %s, want no delay", test.name, gaps[0])
+		}
+	}
+}
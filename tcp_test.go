@@ -1,9 +1,18 @@
 package modbus_test
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	crand "crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
 	"io"
 	"log"
+	"math/big"
 	"math/rand/v2"
+	"net"
 	"os"
 	"testing"
 	"time"
@@ -11,10 +20,6 @@ import (
 	"github.com/pascaldekloe/modbus"
 )
 
-func init() {
-	log.SetOutput(io.Discard)
-}
-
 func Example() {
 	client := modbus.TCPClient{
 		RemoteAddr: "localhost:502",
@@ -37,7 +42,7 @@ func Example() {
 func testTCPClient(t *testing.T) *modbus.TCPClient {
 	addr := os.Getenv("TEST_MODBUS_ADDR")
 	if addr == "" {
-		addr = "localhost:5020"
+		addr = testServer(t).Addr()
 	}
 
 	client, err := modbus.TCPDial(addr, time.Second/2)
@@ -60,6 +65,28 @@ func testTCPClient(t *testing.T) *modbus.TCPClient {
 	return client
 }
 
+// TestServer returns a local server which lives until the end of t.
+func testServer(t *testing.T) *modbus.Server {
+	server, err := modbus.ListenServer("localhost:0")
+	if err != nil {
+		t.Fatal("test server unavailable:", err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return server
+}
+
+// TestServerClient is like testTCPClient, yet it always connects to a local
+// server, such that tests can control the server state.
+func testServerClient(t *testing.T) (*modbus.Server, *modbus.TCPClient) {
+	server := testServer(t)
+	client, err := modbus.TCPDial(server.Addr(), time.Second)
+	if err != nil {
+		t.Fatal("no connection to test server:", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return server, client
+}
+
 func TestTCPRegSingle(t *testing.T) {
 	client := testTCPClient(t)
 
@@ -108,3 +135,226 @@ func TestTCPRegBatch(t *testing.T) {
 		t.Errorf("got values %d, want %d", got, values)
 	}
 }
+
+func TestTCPAddrException(t *testing.T) {
+	server, client := testServerClient(t)
+
+	var buf [2]uint16
+	err := client.ReadHoldRegs(buf[:], 0xffff)
+	if !errors.Is(err, modbus.ErrAddrRange) {
+		t.Errorf("got error %v for registers beyond 0xFFFF, want ErrAddrRange", err)
+	}
+
+	server.SetException(0x03, 0xffff, modbus.ErrAddr)
+	err = client.ReadHoldRegs(buf[:], 0xfffe)
+	if !errors.Is(err, modbus.ErrAddr) {
+		t.Errorf("got error %v for exception, want ErrAddr", err)
+	}
+	var reqErr *modbus.RequestError
+	if !errors.As(err, &reqErr) {
+		t.Fatalf("got error type %T, want *modbus.RequestError", err)
+	}
+	if reqErr.FuncCode != 0x03 || reqErr.Addr != 0xfffe || reqErr.UnitID != client.UnitID {
+		t.Errorf("got request error %+v", reqErr)
+	}
+}
+
+func TestTCPWriteRegsIfChanged(t *testing.T) {
+	client := testTCPClient(t)
+
+	const startAddr = 2001
+	values := []uint16{uint16(rand.Uint()), uint16(rand.Uint())}
+	if err := client.WriteRegs(startAddr, values...); err != nil {
+		t.Fatal(err)
+	}
+
+	wrote, err := client.WriteRegsIfChanged(startAddr, values...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wrote {
+		t.Error("wrote unchanged values")
+	}
+
+	values[1]++
+	wrote, err = client.WriteRegsIfChanged(startAddr, values...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !wrote {
+		t.Error("did not write changed values")
+	}
+	got, err := client.ReadHoldReg(startAddr + 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != values[1] {
+		t.Errorf("got value %d, want %d", got, values[1])
+	}
+}
+
+func TestTCPDialConfig(t *testing.T) {
+	server := testServer(t)
+	server.SetHoldReg(7, 42)
+
+	client, err := modbus.TCPDialConfig(modbus.DialConfig{
+		Addr:           server.Addr(),
+		ConnectTimeout: time.Second,
+		TxTimeout:      time.Second / 2,
+		Dialer:         &net.Dialer{Timeout: time.Hour},
+	})
+	if err != nil {
+		t.Fatal("no connection to test server:", err)
+	}
+	defer client.Close()
+
+	if client.TxTimeout != time.Second/2 {
+		t.Errorf("got TxTimeout %s, want 500ms", client.TxTimeout)
+	}
+	if client.Dialer.Timeout != time.Second {
+		t.Errorf("got dial timeout %s, want 1s", client.Dialer.Timeout)
+	}
+	got, err := client.ReadHoldReg(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 42 {
+		t.Errorf("got register value %d, want 42", got)
+	}
+}
+
+func TestNewTCPClient(t *testing.T) {
+	server := testServer(t)
+	server.SetHoldReg(7, 42)
+
+	conn, err := net.Dial("tcp", server.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := modbus.NewTCPClient(conn, 1)
+	defer client.Close()
+
+	got, err := client.ReadHoldReg(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 42 {
+		t.Errorf("got register value %d, want 42", got)
+	}
+
+	server.Close()
+	if _, err := client.ReadHoldReg(7); err == nil {
+		t.Fatal("read succeeded after server close")
+	}
+	if _, err := client.ReadHoldReg(7); !errors.Is(err, modbus.ErrNoConn) {
+		t.Errorf("got error %v after connection loss, want ErrNoConn", err)
+	}
+}
+
+func TestTLSDial(t *testing.T) {
+	server := testServer(t)
+	server.SetHoldReg(7, 42)
+
+	serverCert, clientCert := testCert(t, "localhost"), testCert(t, "client")
+	roots := x509.NewCertPool()
+	roots.AddCert(serverCert.Leaf)
+
+	// TLS termination in front of the test server
+	ln, err := tls.Listen("tcp", "localhost:0", &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAnyClientCert,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		backend, err := net.Dial("tcp", server.Addr())
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer backend.Close()
+		go io.Copy(backend, conn)
+		io.Copy(conn, backend)
+	}()
+
+	client, err := modbus.TLSDial(ln.Addr().String(), &tls.Config{
+		ServerName:   "localhost",
+		RootCAs:      roots,
+		Certificates: []tls.Certificate{clientCert},
+	}, time.Second)
+	if err != nil {
+		t.Fatal("no TLS connection:", err)
+	}
+	defer client.Close()
+
+	got, err := client.ReadHoldReg(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 42 {
+		t.Errorf("got register value %d, want 42", got)
+	}
+}
+
+// TestCert returns a self-signed certificate for name.
+func testCert(t *testing.T, name string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), crand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(crand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestTCPSignedRegs(t *testing.T) {
+	server, client := testServerClient(t)
+	for i, r := range []uint16{0xfffe, 0xffff, 0xfffd, 0x8000, 0, 0, 1} {
+		server.SetHoldReg(100+uint16(i), r)
+		server.SetInputReg(100+uint16(i), r)
+	}
+
+	if got, err := client.ReadHoldRegInt16(100); err != nil || got != -2 {
+		t.Errorf("holding int16 got (%d, %v), want (-2, nil)", got, err)
+	}
+	if got, err := client.ReadInputRegInt16(101); err != nil || got != -1 {
+		t.Errorf("input int16 got (%d, %v), want (-1, nil)", got, err)
+	}
+	if got, err := client.ReadHoldRegInt32(100); err != nil || got != -65537 {
+		t.Errorf("holding int32 got (%d, %v), want (-65537, nil)", got, err)
+	}
+	if got, err := client.ReadInputRegInt32(102); err != nil || got != -163840 {
+		t.Errorf("input int32 got (%d, %v), want (-163840, nil)", got, err)
+	}
+	if got, err := client.ReadHoldRegInt64(103); err != nil || got != -0x7fff_ffff_ffff_ffff {
+		t.Errorf("holding int64 got (%d, %v), want (-0x7fffffffffffffff, nil)", got, err)
+	}
+	if got, err := client.ReadInputRegInt64(100); err != nil || got != -0x1_0000_0002_8000 {
+		t.Errorf("input int64 got (%#x, %v), want (-0x1000000028000, nil)", got, err)
+	}
+}
@@ -0,0 +1,46 @@
+package modbus
+
+import "strings"
+
+// RegsToString returns the bytes of registers with the high byte first, i.e.,
+// two characters per register. Trailing NUL characters and spaces are omitted.
+func RegsToString(regs []uint16) string {
+	return regsToString(regs, 8, 0)
+}
+
+// RegsToStringByteSwap is like RegsToString, yet with the low byte first.
+func RegsToStringByteSwap(regs []uint16) string {
+	return regsToString(regs, 0, 8)
+}
+
+func regsToString(regs []uint16, firstShift, secondShift int) string {
+	buf := make([]byte, 0, 2*len(regs))
+	for _, r := range regs {
+		buf = append(buf, byte(r>>firstShift), byte(r>>secondShift))
+	}
+	return strings.TrimRight(string(buf), "\x00 ")
+}
+
+// StringToRegs returns the bytes of s with the high byte first, i.e., two
+// characters per register. Strings of an odd length get a NUL character
+// appended.
+func StringToRegs(s string) []uint16 {
+	return stringToRegs(s, 8, 0)
+}
+
+// StringToRegsByteSwap is like StringToRegs, yet with the low byte first.
+func StringToRegsByteSwap(s string) []uint16 {
+	return stringToRegs(s, 0, 8)
+}
+
+func stringToRegs(s string, firstShift, secondShift int) []uint16 {
+	regs := make([]uint16, (len(s)+1)/2)
+	for i := range regs {
+		r := uint16(s[2*i]) << firstShift
+		if 2*i+1 < len(s) {
+			r |= uint16(s[2*i+1]) << secondShift
+		}
+		regs[i] = r
+	}
+	return regs
+}
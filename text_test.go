@@ -0,0 +1,26 @@
+package modbus_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/pascaldekloe/modbus"
+)
+
+func TestStringRegs(t *testing.T) {
+	regs := modbus.StringToRegs("ABC")
+	if want := []uint16{0x4142, 0x4300}; !slices.Equal(regs, want) {
+		t.Errorf("got registers %#04x, want %#04x", regs, want)
+	}
+	if got := modbus.RegsToString(append(regs, 0x2020)); got != "ABC" {
+		t.Errorf("got %q, want %q", got, "ABC")
+	}
+
+	regs = modbus.StringToRegsByteSwap("ABC")
+	if want := []uint16{0x4241, 0x0043}; !slices.Equal(regs, want) {
+		t.Errorf("byte swap got registers %#04x, want %#04x", regs, want)
+	}
+	if got := modbus.RegsToStringByteSwap(regs); got != "ABC" {
+		t.Errorf("byte swap got %q, want %q", got, "ABC")
+	}
+}
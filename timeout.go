@@ -0,0 +1,57 @@
+package modbus
+
+import (
+	"slices"
+	"time"
+)
+
+// AdaptiveTimeout derives transaction timeouts from the latency observed. The
+// timeout is the 99th percentile of recent round trips multiplied by Factor,
+// bound by Min and Max. State is per device, i.e., do not share instances
+// between clients.
+type AdaptiveTimeout struct {
+	// Multiplier applied to the 99th percentile. Zero defaults to 3.
+	Factor float64
+
+	// Floor and ceiling of the timeout. Zero Max omits the ceiling.
+	Min, Max time.Duration
+
+	// ring buffer with the most recent observations
+	samples [64]time.Duration
+	// observation count
+	n uint64
+}
+
+// Timeout returns the current deadline span. The return is zero when no
+// latency was observed yet.
+func (t *AdaptiveTimeout) Timeout() time.Duration {
+	if t.n == 0 {
+		return 0
+	}
+
+	n := min(t.n, uint64(len(t.samples)))
+	var sorted [len(t.samples)]time.Duration
+	copy(sorted[:], t.samples[:n])
+	slices.Sort(sorted[:n])
+	// nearest rank
+	p99 := sorted[(n*99+99)/100-1]
+
+	factor := t.Factor
+	if factor == 0 {
+		factor = 3
+	}
+	d := time.Duration(float64(p99) * factor)
+	if d < t.Min {
+		d = t.Min
+	}
+	if t.Max != 0 && d > t.Max {
+		d = t.Max
+	}
+	return d
+}
+
+// Observe registers the duration of a request–response pair.
+func (t *AdaptiveTimeout) Observe(latency time.Duration) {
+	t.samples[t.n%uint64(len(t.samples))] = latency
+	t.n++
+}
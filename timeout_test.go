@@ -0,0 +1,36 @@
+package modbus_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pascaldekloe/modbus"
+)
+
+func TestAdaptiveTimeout(t *testing.T) {
+	at := modbus.AdaptiveTimeout{
+		Factor: 2,
+		Min:    10 * time.Millisecond,
+		Max:    time.Second,
+	}
+	if got := at.Timeout(); got != 0 {
+		t.Errorf("got %s without observations, want 0", got)
+	}
+
+	for range 100 {
+		at.Observe(time.Millisecond)
+	}
+	if got := at.Timeout(); got != at.Min {
+		t.Errorf("got %s for fast device, want floor %s", got, at.Min)
+	}
+
+	at.Observe(40 * time.Millisecond)
+	if got, want := at.Timeout(), 80*time.Millisecond; got != want {
+		t.Errorf("got %s after slow response, want %s", got, want)
+	}
+
+	at.Observe(time.Minute)
+	if got := at.Timeout(); got != at.Max {
+		t.Errorf("got %s after timeout, want ceiling %s", got, at.Max)
+	}
+}
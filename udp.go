@@ -0,0 +1,155 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// UDPDial binds a connection. UDP has no handshake, i.e., the remote address
+// is not checked for availability. The unit-identifier can be adjusted after
+// UDPDial when needed.
+func UDPDial(addr string, timeout time.Duration) (*UDPClient, error) {
+	conn, err := net.DialTimeout("udp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &UDPClient{
+		Conn:      conn,
+		TxTimeout: timeout,
+		// correct default
+		UnitID: 0xff,
+	}
+	return c, nil
+}
+
+// UDPClient manages a connection for use from within a single goroutine.
+// Frames are the same as with TCP, with one datagram per frame.
+type UDPClient struct {
+	// Buf is (re)used for both reading and writing. The function code
+	// starts at the 8th byte, right after its 7-byte MBAP-header.
+	buf [MaxADUSize]byte
+
+	net.Conn
+
+	// Limit the time for a request–response pair. The zero value omits
+	// timeout protection. Datagrams may get lost without notice.
+	TxTimeout time.Duration
+
+	// read-only transaction counter
+	TxN uint64
+
+	// The unit identifier is supposed to be 0xFF, conform TCPClient.
+	UnitID byte
+}
+
+// Exchange implements the Transport interface. Response bytes stop being valid
+// at the next invocation to the UDPClient.
+func (c *UDPClient) Exchange(funcCode byte, req []byte) ([]byte, error) {
+	if len(req) > len(c.buf)-8 {
+		return nil, ErrLimit
+	}
+	reqLen := 8 + copy(c.buf[8:], req)
+
+	c.TxN++
+	reqHead := mbapHead(c.TxN, reqLen, c.UnitID, funcCode)
+	binary.BigEndian.PutUint64(c.buf[:8], reqHead)
+
+	if c.TxTimeout != 0 {
+		err := c.SetDeadline(time.Now().Add(c.TxTimeout))
+		if err != nil {
+			return nil, fmt.Errorf("timeout on Modbus connection needed: %w", err)
+		}
+		defer c.SetDeadline(time.Time{})
+	}
+
+	_, err := c.Write(c.buf[:reqLen])
+	if err != nil {
+		return nil, fmt.Errorf("Modbus request submission: %w", err)
+	}
+
+	const sizeMask = 0xffff << 16
+	for {
+		readN, err := c.Read(c.buf[:])
+		if err != nil {
+			return nil, fmt.Errorf("Modbus response unavailable: %w", err)
+		}
+		if readN < 9 {
+			return nil, errFrameFit
+		}
+		resHead := binary.BigEndian.Uint64(c.buf[:8])
+		if uint16(resHead>>48) != uint16(reqHead>>48) {
+			continue // late response from a previous transaction
+		}
+
+		// whole frame per datagram
+		if int(resHead>>16&0xffff)+6 != readN {
+			return nil, errors.New("Modbus response datagram does not match frame length")
+		}
+
+		switch resHead &^ sizeMask {
+		case reqHead &^ sizeMask:
+			return c.buf[8:readN], nil
+		case (reqHead &^ sizeMask) | errorFlag:
+			if readN != 9 {
+				return nil, errFrameFit
+			}
+			return nil, Exception(c.buf[8])
+		}
+		return nil, fmt.Errorf("Modbus response frame %#016x… does not match request frame %#016x…",
+			resHead, reqHead)
+	}
+}
+
+// ReadInputReg fetches an input register at the given address.
+func (c *UDPClient) ReadInputReg(addr uint16) (uint16, error) {
+	return Client{c}.ReadInputReg(addr)
+}
+
+// ReadHoldReg fetches a holding register at the given address.
+func (c *UDPClient) ReadHoldReg(addr uint16) (uint16, error) {
+	return Client{c}.ReadHoldReg(addr)
+}
+
+// ReadInputRegs fetches consecutive input-registers at a start address into a
+// read buffer. The return is ErrLimit when buf is larger than 125 entries.
+func (c *UDPClient) ReadInputRegs(buf []uint16, startAddr uint16) error {
+	return Client{c}.ReadInputRegs(buf, startAddr)
+}
+
+// ReadHoldRegs fetches consecutive holding-registers at a start address into a
+// read buffer. The return is ErrLimit when buf is larger than 125 entries.
+func (c *UDPClient) ReadHoldRegs(buf []uint16, startAddr uint16) error {
+	return Client{c}.ReadHoldRegs(buf, startAddr)
+}
+
+// ReadCoils fetches consecutive coils at a start address into a read buffer.
+// The return is ErrLimit when buf is larger than 2000 entries.
+func (c *UDPClient) ReadCoils(buf []bool, startAddr uint16) error {
+	return Client{c}.ReadCoils(buf, startAddr)
+}
+
+// ReadDiscreteInputs fetches consecutive discrete inputs at a start address
+// into a read buffer. The return is ErrLimit when buf is larger than 2000
+// entries.
+func (c *UDPClient) ReadDiscreteInputs(buf []bool, startAddr uint16) error {
+	return Client{c}.ReadDiscreteInputs(buf, startAddr)
+}
+
+// WriteReg updates a single register.
+func (c *UDPClient) WriteReg(addr, value uint16) error {
+	return Client{c}.WriteReg(addr, value)
+}
+
+// WriteCoil switches a single coil.
+func (c *UDPClient) WriteCoil(addr uint16, on bool) error {
+	return Client{c}.WriteCoil(addr, on)
+}
+
+// WriteRegs updates consecutive registers at a start address.
+// The return is ErrLimit when more than 123 values are given.
+func (c *UDPClient) WriteRegs(startAddr uint16, values ...uint16) error {
+	return Client{c}.WriteRegs(startAddr, values...)
+}
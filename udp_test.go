@@ -0,0 +1,73 @@
+package modbus_test
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pascaldekloe/modbus"
+)
+
+func TestUDPClient(t *testing.T) {
+	device, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer device.Close()
+
+	// device answers with a stale datagram first, and then with an
+	// exception on writes, or with registers that hold their address
+	go func() {
+		var buf [260]byte
+		for {
+			n, addr, err := device.ReadFrom(buf[:])
+			if err != nil {
+				return
+			}
+			stale := append([]byte(nil), buf[:n]...)
+			stale[1]--
+			device.WriteTo(stale, addr)
+
+			res := append([]byte(nil), buf[:8]...)
+			if buf[7] == 0x06 {
+				res[7] |= 0x80
+				res = append(res, byte(modbus.ErrAddr))
+			} else {
+				res = append(res, 2, buf[8], buf[9])
+			}
+			res[5] = byte(len(res) - 6)
+			device.WriteTo(res, addr)
+		}
+	}()
+
+	c, err := modbus.UDPDial(device.LocalAddr().String(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	got, err := c.ReadHoldReg(1001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 1001 {
+		t.Errorf("got %d, want 1001", got)
+	}
+
+	if err := c.WriteReg(1, 2); !errors.Is(err, modbus.ErrAddr) {
+		t.Errorf("got write error %v, want ErrAddr", err)
+	}
+
+	// struct literal without UDPDial
+	conn, err := net.Dial("udp", device.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lit := modbus.UDPClient{Conn: conn, TxTimeout: time.Second, UnitID: 0xff}
+	defer lit.Close()
+	got, err = lit.ReadHoldReg(7)
+	if err != nil || got != 7 {
+		t.Errorf("struct literal got (%d, %v), want (7, nil)", got, err)
+	}
+}